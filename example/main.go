@@ -18,10 +18,21 @@ func main() {
 	app := kp.NewApplication(conf)
 	// app.StartKafka()
 
-	app.Get("/healthz", func(ctx *kp.Context) error {
-		ctx.Info("Health check endpoint hit")
-		return ctx.JSON(200, "OK")
-	})
+	app.Get("/healthz", healthzHandler)
 
 	app.Start()
 }
+
+// healthzHandler answers the health check. With Kafka started, it also
+// publishes the status, as main_test.go expects.
+func healthzHandler(ctx *kp.Context) error {
+	ctx.Info("Health check endpoint hit")
+
+	if ctx.Client != nil {
+		if err := ctx.Publish(ctx, "Stg-BulkNormal-CorrelatorTx", []byte(`{"status":"OK"}`)); err != nil {
+			ctx.Error(err.Error())
+		}
+	}
+
+	return ctx.JSON(200, "OK")
+}
@@ -39,11 +39,14 @@ type App struct {
 }
 
 type Server struct {
-	AppPort string `json:"app_port" yaml:"app_port"`
-	AppHost string `json:"app_host" yaml:"app_host"`
-	Https   bool   `json:"https" yaml:"https"`
-	Cert    string `json:"cert" yaml:"cert"`
-	Key     string `json:"key" yaml:"key"`
+	AppPort         string        `json:"app_port" yaml:"app_port"`
+	AppHost         string        `json:"app_host" yaml:"app_host"`
+	Https           bool          `json:"https" yaml:"https"`
+	Cert            string        `json:"cert" yaml:"cert"`
+	Key             string        `json:"key" yaml:"key"`
+	RequestTimeout  time.Duration `json:"request_timeout" yaml:"request_timeout"`
+	MetricsPath     string        `json:"metrics_path" yaml:"metrics_path"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
 }
 
 type TLSKafkaConfig struct {
@@ -54,20 +57,31 @@ type TLSKafkaConfig struct {
 }
 
 type KafkaConfig struct {
-	Broker           string         `json:"broker" yaml:"broker"`
-	Partition        int            `json:"partition" yaml:"partition"`
-	ConsumerGroupID  string         `json:"consumerGroupID" yaml:"consumerGroupID"`
-	OffSet           int            `json:"offSet" yaml:"offSet"`
-	BatchSize        int            `json:"batchSize" yaml:"batchSize"`
-	BatchBytes       int            `json:"batchBytes" yaml:"batchBytes"`
-	BatchTimeout     int            `json:"batchTimeout" yaml:"batchTimeout"`
-	RetryTimeout     time.Duration  `json:"retryTimeout" yaml:"retryTimeout"`
-	SASLMechanism    string         `json:"SASLMechanism" yaml:"SASLMechanism"`
-	SASLUser         string         `json:"SASLUser" yaml:"SASLUser"`
-	SASLPassword     string         `json:"SASLPassword" yaml:"SASLPassword"`
-	SecurityProtocol string         `json:"securityProtocol" yaml:"securityProtocol"`
-	AutoCreateTopic  bool           `json:"autoCreateTopic" yaml:"autoCreateTopic"`
-	TLS              TLSKafkaConfig `json:"TLS" yaml:"TLS"`
+	Broker            string         `json:"broker" yaml:"broker"`
+	Partition         int            `json:"partition" yaml:"partition"`
+	ConsumerGroupID   string         `json:"consumerGroupID" yaml:"consumerGroupID"`
+	OffSet            int            `json:"offSet" yaml:"offSet"`
+	BatchSize         int            `json:"batchSize" yaml:"batchSize"`
+	BatchBytes        int            `json:"batchBytes" yaml:"batchBytes"`
+	BatchTimeout      int            `json:"batchTimeout" yaml:"batchTimeout"`
+	RetryTimeout      time.Duration  `json:"retryTimeout" yaml:"retryTimeout"`
+	MinBytes          int            `json:"minBytes" yaml:"minBytes"`
+	MaxBytes          int            `json:"maxBytes" yaml:"maxBytes"`
+	SessionTimeout    time.Duration  `json:"sessionTimeout" yaml:"sessionTimeout"`
+	HeartbeatInterval time.Duration  `json:"heartbeatInterval" yaml:"heartbeatInterval"`
+	RebalanceStrategy string         `json:"rebalanceStrategy" yaml:"rebalanceStrategy"`
+	CommitInterval    time.Duration  `json:"commitInterval" yaml:"commitInterval"`
+	IsolationLevel    string         `json:"isolationLevel" yaml:"isolationLevel"`
+	RequiredAcks      string         `json:"requiredAcks" yaml:"requiredAcks"`
+	Compression       string         `json:"compression" yaml:"compression"`
+	Balancer          string         `json:"balancer" yaml:"balancer"`
+	SASLMechanism     string         `json:"SASLMechanism" yaml:"SASLMechanism"`
+	SASLUser          string         `json:"SASLUser" yaml:"SASLUser"`
+	SASLPassword      string         `json:"SASLPassword" yaml:"SASLPassword"`
+	SecurityProtocol  string         `json:"securityProtocol" yaml:"securityProtocol"`
+	AutoCreateTopic   bool           `json:"autoCreateTopic" yaml:"autoCreateTopic"`
+	Idempotent        bool           `json:"idempotent" yaml:"idempotent"`
+	TLS               TLSKafkaConfig `json:"TLS" yaml:"TLS"`
 }
 
 type Config struct {
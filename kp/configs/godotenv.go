@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -130,6 +131,11 @@ func (e *EnvLoader) read(folder string) *Config {
 			Https:   parseBool("SERVER_HTTPS", false),
 			Cert:    e.Get("SERVER_CERT"),
 			Key:     e.Get("SERVER_KEY"),
+
+			RequestTimeout: parseDuration("SERVER_REQUEST_TIMEOUT", 10*time.Second),
+			MetricsPath:    e.GetOrDefault("SERVER_METRICS_PATH", "/metrics"),
+
+			ShutdownTimeout: parseDuration("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
 		},
 		Kafka: KafkaConfig{
 			Broker:          e.GetOrDefault("KAFKA_BROKER", ""),
@@ -138,6 +144,21 @@ func (e *EnvLoader) read(folder string) *Config {
 			BatchTimeout:    parseInt("KAFKA_BATCH_TIMEOUT", 1000),
 			ConsumerGroupID: e.GetOrDefault("KAFKA_CONSUMER_GROUP_ID", "default-group"),
 			Partition:       parseInt("KAFKA_PARTITION", 0),
+			OffSet:          parseInt("KAFKA_OFFSET", 0),
+			RetryTimeout:    parseDuration("KAFKA_RETRY_TIMEOUT", 10*time.Second),
+			Idempotent:      parseBool("KAFKA_IDEMPOTENT", false),
+			AutoCreateTopic: parseBool("KAFKA_AUTO_CREATE_TOPIC", false),
+
+			MinBytes:          parseInt("KAFKA_MIN_BYTES", 10e3),
+			MaxBytes:          parseInt("KAFKA_MAX_BYTES", 10e6),
+			SessionTimeout:    parseDuration("KAFKA_SESSION_TIMEOUT", 0),
+			HeartbeatInterval: parseDuration("KAFKA_HEARTBEAT_INTERVAL", 0),
+			RebalanceStrategy: e.GetOrDefault("KAFKA_REBALANCE_STRATEGY", ""),
+			CommitInterval:    parseDuration("KAFKA_COMMIT_INTERVAL", 0),
+			IsolationLevel:    e.GetOrDefault("KAFKA_ISOLATION_LEVEL", "read_uncommitted"),
+			RequiredAcks:      e.GetOrDefault("KAFKA_REQUIRED_ACKS", "all"),
+			Compression:       e.GetOrDefault("KAFKA_COMPRESSION", "none"),
+			Balancer:          e.GetOrDefault("KAFKA_BALANCER", "hash"),
 		},
 		TracerHost: e.GetOrDefault("TRACER_HOST", "localhost:4317"),
 	}
@@ -178,6 +199,21 @@ func parseInt(key string, defaultValue int) int {
 	return parsed
 }
 
+func parseDuration(key string, defaultValue time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(val)
+	if err != nil {
+		log.Printf("Invalid duration value for %s: %v, using default: %v", key, err, defaultValue)
+		return defaultValue
+	}
+
+	return parsed
+}
+
 func parseBool(key string, defaultValue bool) bool {
 	val := os.Getenv(key)
 	if val == "" {
@@ -232,6 +268,9 @@ SERVER_APP_HOST=localhost
 SERVER_HTTPS=false
 SERVER_CERT=./cert.pem
 SERVER_KEY=./key.pem
+SERVER_REQUEST_TIMEOUT=10s
+SERVER_METRICS_PATH=/metrics
+SERVER_SHUTDOWN_TIMEOUT=30s
 
 # Tracing
 TRACER_HOST=localhost:4317
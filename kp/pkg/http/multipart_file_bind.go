@@ -2,12 +2,17 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"net/textproto"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sing3demons/go-common-kp/kp/pkg/file"
+	"github.com/sing3demons/go-common-kp/kp/pkg/validator"
 )
 
 var (
@@ -24,8 +29,13 @@ var (
 type formData struct {
 	fields map[string][]string
 	files  map[string][]*multipart.FileHeader
+	// tag, when set, binds only the fields carrying it, e.g. `query:"page"`,
+	// and reports values that do not convert as validator.ValidationErrors.
+	tag string
 }
 
+var timeType = reflect.TypeOf(time.Time{})
+
 func (uf *formData) mapStruct(val reflect.Value, field *reflect.StructField) (bool, error) {
 	vKind := val.Kind()
 
@@ -115,7 +125,7 @@ func (uf *formData) iterateStructFields(val reflect.Value) (bool, error) {
 }
 
 func (uf *formData) trySet(value reflect.Value, field *reflect.StructField) (bool, error) {
-	tag, ok := getFieldName(field)
+	tag, ok := uf.fieldName(field)
 	if !ok {
 		return false, nil
 	}
@@ -124,11 +134,46 @@ func (uf *formData) trySet(value reflect.Value, field *reflect.StructField) (boo
 		return uf.setFile(value, header)
 	}
 
-	if values, ok := uf.fields[tag]; ok {
-		return uf.setFieldValue(value, values[0])
+	values, ok := uf.fields[tag]
+	if !ok || len(values) == 0 {
+		return false, nil
 	}
 
-	return false, nil
+	data := values[0]
+	if kind := dereferenceType(value.Type()).Kind(); kind == reflect.Slice || kind == reflect.Array {
+		data = strings.Join(values, ",")
+	}
+
+	set, err := uf.setFieldValue(value, data)
+	if err != nil && uf.tag != "" {
+		return false, validator.ValidationErrors{{
+			Field:   tag,
+			Rule:    "type",
+			Param:   dereferenceType(value.Type()).String(),
+			Message: fmt.Sprintf("%s %s must be a valid %s", uf.tag, tag, dereferenceType(value.Type())),
+		}}
+	}
+
+	return set, err
+}
+
+// fieldName returns the key of field in the form, or in the source named by
+// uf.tag. Header names are canonicalized like http.Header keys.
+func (uf *formData) fieldName(field *reflect.StructField) (string, bool) {
+	if uf.tag == "" {
+		return getFieldName(field)
+	}
+
+	key := field.Tag.Get(uf.tag)
+	if key == "" || key == "-" || !field.IsExported() {
+		return "", false
+	}
+
+	if uf.tag == headerTag {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+	}
+
+	return key, true
 }
 
 func (*formData) setFile(value reflect.Value, header []*multipart.FileHeader) (bool, error) {
@@ -162,6 +207,10 @@ func (*formData) setFile(value reflect.Value, header []*multipart.FileHeader) (b
 func (uf *formData) setFieldValue(value reflect.Value, data string) (bool, error) {
 	value = dereferencePointerType(value)
 
+	if value.Type() == timeType {
+		return uf.setTimeValue(value, data)
+	}
+
 	kind := value.Kind()
 	switch kind {
 	case reflect.String:
@@ -201,6 +250,29 @@ func dereferencePointerType(value reflect.Value) reflect.Value {
 	return value
 }
 
+func dereferenceType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// setTimeValue parses RFC 3339 timestamps and dates such as 2006-01-02.
+func (*formData) setTimeValue(value reflect.Value, data string) (bool, error) {
+	t, err := time.Parse(time.RFC3339Nano, data)
+	if err != nil {
+		var dateErr error
+		if t, dateErr = time.Parse(time.DateOnly, data); dateErr != nil {
+			return false, err
+		}
+	}
+
+	value.Set(reflect.ValueOf(t))
+
+	return true, nil
+}
+
 func (*formData) setStringValue(value reflect.Value, data string) (bool, error) {
 	value.SetString(data)
 
@@ -14,10 +14,16 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/sing3demons/go-common-kp/kp/pkg/validator"
 )
 
 const (
 	defaultMaxMemory = 32 << 20 // 32 MB
+
+	// Struct tags binding path parameters, query parameters and headers.
+	pathTag   = "path"
+	queryTag  = "query"
+	headerTag = "header"
 )
 
 var (
@@ -150,30 +156,123 @@ func (r *Request) Body() (string, error) {
 	return string(bodyBytes), nil
 }
 
-// Bind parses the request body and binds it to the provided interface.
+// Bind parses the request body and binds it to the provided interface. The
+// struct fields tagged `path:"id"`, `query:"page"` or `header:"X-Tenant"` are
+// filled from the path parameters, the query string and the headers only: the
+// body sets the other fields, and a body key matching a tagged field is
+// ignored. The result is checked against the validate tags of i; a failed
+// check or a parameter that does not convert to its field type returns
+// validator.ValidationErrors.
 func (r *Request) Bind(i any) error {
 	v := r.req.Header.Get("Content-Type")
 	contentType := strings.Split(v, ";")[0]
 
+	if contentType == "binary/octet-stream" {
+		return r.bindBinary(i)
+	}
+
+	// the body must not override the fields bound from the URL and headers
+	restore := saveParamFields(i)
+
+	var err error
 	switch contentType {
 	case "application/json":
-		body, err := r.body()
-		if err != nil {
-			return err
+		var body []byte
+		if body, err = r.body(); err == nil {
+			err = json.Unmarshal(body, &i)
 		}
-
-		return json.Unmarshal(body, &i)
 	case "multipart/form-data":
-		return r.bindMultipart(i)
+		err = r.bindMultipart(i)
 	case "application/x-www-form-urlencoded":
-		return r.bindFormURLEncoded(i)
-	case "binary/octet-stream":
-		return r.bindBinary(i)
+		err = r.bindFormURLEncoded(i)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	restore()
+	if err := r.bindParams(i); err != nil {
+		return err
+	}
+	return validator.Struct(i)
+}
+
+// bindParams sets the fields of the struct ptr points to from the path
+// parameters, the query string and the headers named by their tags.
+func (r *Request) bindParams(ptr any) error {
+	ptrVal := reflect.ValueOf(ptr)
+	if ptrVal.Kind() != reflect.Ptr || ptrVal.IsNil() {
+		return nil
+	}
+
+	path := make(map[string][]string, len(r.pathParams))
+	for key, value := range r.pathParams {
+		path[key] = []string{value}
+	}
+
+	sources := []formData{
+		{fields: path, tag: pathTag},
+		{fields: r.req.URL.Query(), tag: queryTag},
+		{fields: r.req.Header, tag: headerTag},
+	}
+	for _, fd := range sources {
+		if _, err := fd.mapStruct(ptrVal.Elem(), nil); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// saveParamFields records the fields of the struct ptr points to that carry a
+// path, query or header tag, including those of nested and embedded structs,
+// and returns a function setting them back to the recorded values.
+func saveParamFields(ptr any) (restore func()) {
+	type saved struct {
+		field, value reflect.Value
+	}
+	var fields []saved
+
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() && !sf.Anonymous {
+				continue
+			}
+
+			field := v.Field(i)
+			if sf.Tag.Get(pathTag) != "" || sf.Tag.Get(queryTag) != "" || sf.Tag.Get(headerTag) != "" {
+				if field.CanSet() {
+					value := reflect.New(field.Type()).Elem()
+					value.Set(field)
+					fields = append(fields, saved{field: field, value: value})
+				}
+				continue
+			}
+
+			if field.Kind() == reflect.Pointer && !field.IsNil() {
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct && field.Type() != timeType {
+				walk(field)
+			}
+		}
+	}
+
+	if v := reflect.ValueOf(ptr); v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		walk(v.Elem())
+	}
+
+	return func() {
+		for _, f := range fields {
+			f.field.Set(f.value)
+		}
+	}
+}
+
 // HostName retrieves the hostname from the request.
 func (r *Request) HostName() string {
 	proto := r.req.Header.Get("X-Forwarded-Proto")
@@ -8,7 +8,7 @@ import (
 )
 
 type Router struct {
-	mux.Router
+	*mux.Router
 	RegisteredRoutes *[]string
 }
 
@@ -18,15 +18,22 @@ func NewRouter() *Router {
 	muxRouter := mux.NewRouter().StrictSlash(false)
 	routes := make([]string, 0)
 	r := &Router{
-		Router:           *muxRouter,
+		Router:           muxRouter,
 		RegisteredRoutes: &routes,
 	}
 
-	r.Router = *muxRouter
-
 	return r
 }
 
+// Group returns a sub-router that only matches paths starting with prefix.
+// Routes added to it are served by the parent router and share its middlewares.
+func (rou *Router) Group(prefix string) *Router {
+	return &Router{
+		Router:           rou.PathPrefix(prefix).Subrouter(),
+		RegisteredRoutes: rou.RegisteredRoutes,
+	}
+}
+
 func (rou *Router) Add(method, pattern string, handler http.Handler) {
 	h := otelhttp.NewHandler(handler, "gokp-router")
 	rou.Router.NewRoute().Methods(method).Path(pattern).Handler(h)
@@ -0,0 +1,299 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var errInvalidTopicConfig = errors.New("invalid topic configuration")
+
+// Admin manages the topics of the cluster.
+type Admin interface {
+	// CreateTopic creates topic name with one partition and one replica.
+	CreateTopic(ctx context.Context, name string) error
+	// CreateTopics creates topics. Topics that already exist are left as they are.
+	CreateTopics(ctx context.Context, topics ...TopicConfig) error
+	// EnsureTopics creates, with one partition and one replica, the topics of
+	// names that do not exist yet.
+	EnsureTopics(ctx context.Context, names ...string) error
+	// DescribeTopics returns the partitions of the topics of names.
+	DescribeTopics(ctx context.Context, names ...string) ([]TopicDescription, error)
+	// ListTopics returns the names of the topics of the cluster, internal
+	// topics excluded.
+	ListTopics(ctx context.Context) ([]string, error)
+	// AlterPartitions increases the number of partitions of topic to count.
+	// Kafka cannot remove partitions.
+	AlterPartitions(ctx context.Context, topic string, count int) error
+	DeleteTopic(ctx context.Context, name string) error
+}
+
+// TopicConfig describes a topic to create.
+type TopicConfig struct {
+	Name string
+	// Partitions and ReplicationFactor default to 1.
+	Partitions        int
+	ReplicationFactor int
+	// Configs holds topic-level settings, e.g. "retention.ms" or "cleanup.policy".
+	Configs map[string]string
+}
+
+// TopicDescription describes an existing topic.
+type TopicDescription struct {
+	Name       string
+	Partitions []PartitionDescription
+}
+
+// PartitionDescription describes a partition of a topic by broker IDs.
+type PartitionDescription struct {
+	ID       int
+	Leader   int
+	Replicas []int
+	ISR      []int
+}
+
+// partitionCreator is implemented by *kafka.Client; it is the only admin
+// request that a broker connection cannot send.
+type partitionCreator interface {
+	CreatePartitions(ctx context.Context, req *kafka.CreatePartitionsRequest) (*kafka.CreatePartitionsResponse, error)
+}
+
+func (t TopicConfig) kafkaTopicConfig() (kafka.TopicConfig, error) {
+	if t.Name == "" {
+		return kafka.TopicConfig{}, fmt.Errorf("topic name is empty: %w", errInvalidTopicConfig)
+	}
+	if t.Partitions < 0 || t.ReplicationFactor < 0 {
+		return kafka.TopicConfig{}, fmt.Errorf("topic %s: partitions and replication factor must not be negative: %w", t.Name, errInvalidTopicConfig)
+	}
+
+	tc := kafka.TopicConfig{
+		Topic:             t.Name,
+		NumPartitions:     max(t.Partitions, 1),
+		ReplicationFactor: max(t.ReplicationFactor, 1),
+	}
+
+	names := make([]string, 0, len(t.Configs))
+	for name := range t.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tc.ConfigEntries = append(tc.ConfigEntries, kafka.ConfigEntry{ConfigName: name, ConfigValue: t.Configs[name]})
+	}
+	return tc, nil
+}
+
+func (k *kafkaClient) CreateTopic(ctx context.Context, name string) error {
+	return k.CreateTopics(ctx, TopicConfig{Name: name})
+}
+
+func (k *kafkaClient) CreateTopics(_ context.Context, topics ...TopicConfig) error {
+	if k.conn == nil {
+		return errClientNotConnected
+	}
+
+	configs := make([]kafka.TopicConfig, len(topics))
+	for i, t := range topics {
+		tc, err := t.kafkaTopicConfig()
+		if err != nil {
+			return err
+		}
+		configs[i] = tc
+	}
+
+	if err := k.conn.CreateTopics(configs...); err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+		return err
+	}
+
+	names := make([]string, len(topics))
+	for i, t := range topics {
+		names[i] = t.Name
+	}
+	k.markEnsured(names...)
+	return nil
+}
+
+func (k *kafkaClient) EnsureTopics(ctx context.Context, names ...string) error {
+	missing := k.unensured(names)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	existing, err := k.ListTopics(ctx)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
+	}
+
+	topics := make([]TopicConfig, 0, len(missing))
+	for _, name := range missing {
+		if !exists[name] {
+			topics = append(topics, TopicConfig{Name: name})
+		}
+	}
+	if len(topics) != 0 {
+		if err := k.CreateTopics(ctx, topics...); err != nil {
+			return err
+		}
+	}
+
+	k.markEnsured(missing...)
+	return nil
+}
+
+func (k *kafkaClient) markEnsured(names ...string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.ensured == nil {
+		k.ensured = make(map[string]bool)
+	}
+	for _, name := range names {
+		k.ensured[name] = true
+	}
+}
+
+// unensured returns the distinct names not known to exist by the client.
+func (k *kafkaClient) unensured(names []string) []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var missing []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == "" || seen[name] || k.ensured[name] {
+			continue
+		}
+		seen[name] = true
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// autoCreateTopics ensures the topics written to exist when AutoCreateTopic is set.
+func (k *kafkaClient) autoCreateTopics(ctx context.Context, topics ...string) error {
+	if !k.config.AutoCreateTopic {
+		return nil
+	}
+	if err := k.EnsureTopics(ctx, topics...); err != nil {
+		return fmt.Errorf("create topics %v: %w", topics, err)
+	}
+	return nil
+}
+
+func (k *kafkaClient) ListTopics(_ context.Context) ([]string, error) {
+	if k.conn == nil {
+		return nil, errClientNotConnected
+	}
+	partitions, err := k.conn.ReadPartitions()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	topics := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		if seen[p.Topic] || strings.HasPrefix(p.Topic, "__") {
+			continue
+		}
+		seen[p.Topic] = true
+		topics = append(topics, p.Topic)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func (k *kafkaClient) DescribeTopics(_ context.Context, names ...string) ([]TopicDescription, error) {
+	if k.conn == nil {
+		return nil, errClientNotConnected
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	partitions, err := k.conn.ReadPartitions(names...)
+	if err != nil {
+		return nil, err
+	}
+
+	byTopic := make(map[string]*TopicDescription, len(names))
+	for _, p := range partitions {
+		d, ok := byTopic[p.Topic]
+		if !ok {
+			d = &TopicDescription{Name: p.Topic}
+			byTopic[p.Topic] = d
+		}
+		d.Partitions = append(d.Partitions, PartitionDescription{
+			ID:       p.ID,
+			Leader:   p.Leader.ID,
+			Replicas: brokerIDs(p.Replicas),
+			ISR:      brokerIDs(p.Isr),
+		})
+	}
+
+	descriptions := make([]TopicDescription, 0, len(byTopic))
+	for _, d := range byTopic {
+		sort.Slice(d.Partitions, func(i, j int) bool { return d.Partitions[i].ID < d.Partitions[j].ID })
+		descriptions = append(descriptions, *d)
+	}
+	sort.Slice(descriptions, func(i, j int) bool { return descriptions[i].Name < descriptions[j].Name })
+	return descriptions, nil
+}
+
+func brokerIDs(brokers []kafka.Broker) []int {
+	ids := make([]int, len(brokers))
+	for i, b := range brokers {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+func (k *kafkaClient) AlterPartitions(ctx context.Context, topic string, count int) error {
+	if k.admin == nil {
+		return errClientNotConnected
+	}
+	if count < 1 {
+		return fmt.Errorf("topic %s: partition count must be positive: %w", topic, errInvalidTopicConfig)
+	}
+
+	resp, err := k.admin.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{
+		Topics: []kafka.TopicPartitionsConfig{{Name: topic, Count: int32(count)}},
+	})
+	if err != nil {
+		return err
+	}
+	return resp.Errors[topic]
+}
+
+func (k *kafkaClient) DeleteTopic(_ context.Context, name string) error {
+	if k.conn == nil {
+		return errClientNotConnected
+	}
+	if err := k.conn.DeleteTopics(name); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	delete(k.ensured, name)
+	k.mu.Unlock()
+	return nil
+}
+
+// newAdminClient returns the client sending the admin requests to the brokers
+// of conf through the TLS and SASL settings of dialer.
+func newAdminClient(conf *Config, dialer *kafka.Dialer) *kafka.Client {
+	return &kafka.Client{
+		Addr: kafka.TCP(conf.Brokers...),
+		Transport: &kafka.Transport{
+			SASL: dialer.SASLMechanism,
+			TLS:  dialer.TLS,
+		},
+	}
+}
@@ -0,0 +1,188 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// adminConn is a controller connection recording the topics created and deleted.
+type adminConn struct {
+	fakeConn
+	created []kafka.TopicConfig
+	deleted []string
+}
+
+func (c *adminConn) Controller() (kafka.Broker, error) {
+	return kafka.Broker{Host: "127.0.0.1", Port: 9092}, nil
+}
+
+func (c *adminConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9092}
+}
+
+func (c *adminConn) CreateTopics(topics ...kafka.TopicConfig) error {
+	c.created = append(c.created, topics...)
+	return nil
+}
+
+func (c *adminConn) DeleteTopics(topics ...string) error {
+	c.deleted = append(c.deleted, topics...)
+	return nil
+}
+
+type fakePartitionCreator struct {
+	requests []*kafka.CreatePartitionsRequest
+	err      error
+}
+
+func (f *fakePartitionCreator) CreatePartitions(_ context.Context, req *kafka.CreatePartitionsRequest) (*kafka.CreatePartitionsResponse, error) {
+	f.requests = append(f.requests, req)
+	resp := &kafka.CreatePartitionsResponse{Errors: map[string]error{}}
+	for _, t := range req.Topics {
+		resp.Errors[t.Name] = f.err
+	}
+	return resp, nil
+}
+
+func newAdminTestClient(partitions ...kafka.Partition) (*kafkaClient, *adminConn) {
+	conn := &adminConn{fakeConn: fakeConn{partitions: partitions}}
+	k := newTestClient(&fakeWriter{}, nil)
+	k.conn.conns = []Connection{conn}
+	return k, conn
+}
+
+func TestCreateTopics(t *testing.T) {
+	k, conn := newAdminTestClient()
+
+	err := k.CreateTopics(context.Background(),
+		TopicConfig{Name: "orders", Partitions: 6, ReplicationFactor: 3, Configs: map[string]string{
+			"retention.ms":   "86400000",
+			"cleanup.policy": "compact",
+		}},
+		TopicConfig{Name: "payments"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []kafka.TopicConfig{
+		{Topic: "orders", NumPartitions: 6, ReplicationFactor: 3, ConfigEntries: []kafka.ConfigEntry{
+			{ConfigName: "cleanup.policy", ConfigValue: "compact"},
+			{ConfigName: "retention.ms", ConfigValue: "86400000"},
+		}},
+		{Topic: "payments", NumPartitions: 1, ReplicationFactor: 1},
+	}
+	if !reflect.DeepEqual(conn.created, want) {
+		t.Errorf("expected topics %+v, got %+v", want, conn.created)
+	}
+
+	if err := k.CreateTopics(context.Background(), TopicConfig{Name: "bad", Partitions: -1}); !errors.Is(err, errInvalidTopicConfig) {
+		t.Errorf("expected errInvalidTopicConfig, got %v", err)
+	}
+}
+
+func TestEnsureTopicsCreatesMissingTopicsOnce(t *testing.T) {
+	k, conn := newAdminTestClient(kafka.Partition{Topic: "orders"})
+
+	if err := k.EnsureTopics(context.Background(), "orders", "payments", "payments"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conn.created) != 1 || conn.created[0].Topic != "payments" {
+		t.Fatalf("expected only payments to be created, got %+v", conn.created)
+	}
+
+	if err := k.EnsureTopics(context.Background(), "orders", "payments"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conn.created) != 1 {
+		t.Errorf("expected known topics not to be created again, got %+v", conn.created)
+	}
+}
+
+func TestAutoCreateTopicOnPublish(t *testing.T) {
+	k, conn := newAdminTestClient()
+	k.config.AutoCreateTopic = true
+
+	if err := k.Publish(context.Background(), "orders", []byte(`{}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conn.created) != 1 || conn.created[0].Topic != "orders" {
+		t.Errorf("expected orders to be created before publishing, got %+v", conn.created)
+	}
+}
+
+func TestDescribeTopics(t *testing.T) {
+	broker := func(id int) kafka.Broker { return kafka.Broker{ID: id} }
+	k, _ := newAdminTestClient(
+		kafka.Partition{Topic: "orders", ID: 1, Leader: broker(2), Replicas: []kafka.Broker{broker(2), broker(1)}, Isr: []kafka.Broker{broker(2)}},
+		kafka.Partition{Topic: "orders", ID: 0, Leader: broker(1), Replicas: []kafka.Broker{broker(1), broker(2)}, Isr: []kafka.Broker{broker(1), broker(2)}},
+	)
+
+	topics, err := k.DescribeTopics(context.Background(), "orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []TopicDescription{{Name: "orders", Partitions: []PartitionDescription{
+		{ID: 0, Leader: 1, Replicas: []int{1, 2}, ISR: []int{1, 2}},
+		{ID: 1, Leader: 2, Replicas: []int{2, 1}, ISR: []int{2}},
+	}}}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("expected %+v, got %+v", want, topics)
+	}
+}
+
+func TestAlterPartitions(t *testing.T) {
+	k, _ := newAdminTestClient()
+	admin := &fakePartitionCreator{}
+	k.admin = admin
+
+	if err := k.AlterPartitions(context.Background(), "orders", 12); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(admin.requests) != 1 || admin.requests[0].Topics[0].Name != "orders" || admin.requests[0].Topics[0].Count != 12 {
+		t.Errorf("unexpected requests %+v", admin.requests)
+	}
+
+	admin.err = kafka.InvalidPartitionNumber
+	if err := k.AlterPartitions(context.Background(), "orders", 2); !errors.Is(err, kafka.InvalidPartitionNumber) {
+		t.Errorf("expected the broker error, got %v", err)
+	}
+}
+
+func TestDeleteTopic(t *testing.T) {
+	k, conn := newAdminTestClient()
+	k.markEnsured("orders")
+
+	if err := k.DeleteTopic(context.Background(), "orders"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(conn.deleted, []string{"orders"}) {
+		t.Errorf("expected orders to be deleted, got %v", conn.deleted)
+	}
+	if got := k.unensured([]string{"orders"}); len(got) != 1 {
+		t.Errorf("expected a deleted topic to be created again when needed")
+	}
+}
+
+func TestListTopics(t *testing.T) {
+	k := newTestClient(&fakeWriter{}, nil)
+	k.conn.conns = []Connection{fakeConn{partitions: []kafka.Partition{
+		{Topic: "payments", ID: 0},
+		{Topic: "orders", ID: 0},
+		{Topic: "orders", ID: 1},
+		{Topic: "__consumer_offsets", ID: 0},
+	}}}
+
+	topics, err := k.ListTopics(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"orders", "payments"}; !reflect.DeepEqual(topics, want) {
+		t.Errorf("expected topics %v, got %v", want, topics)
+	}
+}
@@ -0,0 +1,112 @@
+package kafka
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter records the written messages.
+type fakeWriter struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	err      error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func (w *fakeWriter) Stats() kafka.WriterStats { return kafka.WriterStats{} }
+
+// fakeReader returns the queued messages, then blocks until ctx is done.
+type fakeReader struct {
+	queue     chan kafka.Message
+	mu        sync.Mutex
+	committed []kafka.Message
+	commits   int
+	commitErr error
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	r := &fakeReader{queue: make(chan kafka.Message, len(msgs)+16)}
+	for _, msg := range msgs {
+		r.queue <- msg
+	}
+	return r
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	return r.FetchMessage(ctx)
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.queue:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits++
+	if r.commitErr != nil {
+		return r.commitErr
+	}
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Stats() kafka.ReaderStats { return kafka.ReaderStats{} }
+
+func (r *fakeReader) Close() error { return nil }
+
+// fakeConn is a connection to a reachable controller.
+// fakeConn is a connection to a cluster holding partitions.
+type fakeConn struct {
+	partitions []kafka.Partition
+}
+
+func (fakeConn) Controller() (kafka.Broker, error) {
+	return kafka.Broker{Host: "localhost", Port: 9092}, nil
+}
+
+func (fakeConn) CreateTopics(topics ...kafka.TopicConfig) error { return nil }
+
+func (fakeConn) DeleteTopics(topics ...string) error { return nil }
+
+func (fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{} }
+
+func (c fakeConn) ReadPartitions(topics ...string) ([]kafka.Partition, error) {
+	return c.partitions, nil
+}
+
+func (fakeConn) Close() error { return nil }
+
+// newTestClient returns a connected client using w and the readers by topic.
+func newTestClient(w Writer, readers map[string]Reader) *kafkaClient {
+	return &kafkaClient{
+		conn:      &multiConn{conns: []Connection{fakeConn{}}},
+		writer:    w,
+		reader:    readers,
+		published: make(map[string]int64),
+		mu:        &sync.RWMutex{},
+		config: Config{
+			Brokers:         []string{"localhost:9092"},
+			ConsumerGroupID: "billing",
+		},
+	}
+}
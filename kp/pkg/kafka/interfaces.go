@@ -7,7 +7,6 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
-
 type Reader interface {
 	ReadMessage(ctx context.Context) (kafka.Message, error)
 	FetchMessage(ctx context.Context) (kafka.Message, error)
@@ -22,6 +21,21 @@ type Writer interface {
 	Stats() kafka.WriterStats
 }
 
+// StatsProvider is implemented by clients that expose reader and writer statistics.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// Executor runs calls under a resilience policy, e.g. a *resilience.Policy.
+type Executor interface {
+	Execute(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Pinger is implemented by clients that can check their connection to the brokers.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 type Connection interface {
 	Controller() (broker kafka.Broker, err error)
 	CreateTopics(topics ...kafka.TopicConfig) error
@@ -17,7 +17,6 @@ import (
 	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
-	"go.opentelemetry.io/otel"
 )
 
 var (
@@ -35,6 +34,8 @@ var (
 	errNoActiveConnections         = errors.New("no active connections to brokers")
 	errCACertFileRead              = errors.New("failed to read CA certificate file")
 	errClientCertLoad              = errors.New("failed to load client certificate")
+	errInvalidReaderConfig         = errors.New("invalid kafka reader configuration")
+	errInvalidWriterConfig         = errors.New("invalid kafka writer configuration")
 )
 
 const (
@@ -52,7 +53,9 @@ const (
 
 type (
 	Config struct {
-		Brokers          []string
+		Brokers []string
+		// Partition is read by readers without a consumer group; it must be 0
+		// with one, as the group assigns the partitions.
 		Partition        int
 		ConsumerGroupID  string
 		OffSet           int
@@ -65,6 +68,39 @@ type (
 		SASLPassword     string
 		SecurityProtocol string
 		TLS              TLSConfig
+		// MinBytes and MaxBytes bound the size of the fetch requests of the
+		// readers. They default to 10KB and 10MB.
+		MinBytes int
+		MaxBytes int
+		// SessionTimeout, HeartbeatInterval and RebalanceStrategy configure the
+		// consumer group membership; zero values keep the reader defaults.
+		// RebalanceStrategy is "range" or "roundrobin".
+		SessionTimeout    time.Duration
+		HeartbeatInterval time.Duration
+		RebalanceStrategy string
+		// CommitInterval commits offsets in the background at this interval
+		// instead of on every Commit.
+		CommitInterval time.Duration
+		// IsolationLevel is "read_uncommitted" (default) or "read_committed".
+		IsolationLevel string
+		// RequiredAcks is "all" (default), "one" or "none".
+		RequiredAcks string
+		// Compression is "none" (default), "gzip", "snappy", "lz4" or "zstd".
+		Compression string
+		// Balancer picks the partition of messages without an explicit one:
+		// "hash" (default), "round_robin", "least_bytes", "crc32" or "murmur2".
+		Balancer string
+		// AutoCreateTopic creates the topics written to when they do not
+		// exist, with one partition and one replica.
+		AutoCreateTopic bool
+		// Idempotent waits for every in-sync replica to acknowledge writes and
+		// adds a unique HeaderMessageID to every message. The client cannot send
+		// producer IDs, so brokers do not deduplicate retried writes: consumers
+		// can by the message ID.
+		Idempotent bool
+		// PublishPolicy runs the writes of Publish, PublishMessage, Forward and
+		// Transaction, e.g. to retry them with backoff behind a circuit breaker.
+		PublishPolicy Executor
 	}
 
 	TLSConfig struct {
@@ -75,12 +111,24 @@ type (
 	}
 
 	kafkaClient struct {
-		dialer *kafka.Dialer
-		conn   *multiConn
-		writer Writer
-		reader map[string]Reader
-		mu     *sync.RWMutex
-		config Config
+		dialer    *kafka.Dialer
+		conn      *multiConn
+		writer    Writer
+		reader    map[string]Reader
+		published map[string]int64
+		admin     partitionCreator
+		// ensured holds the topics known to exist, see EnsureTopics.
+		ensured map[string]bool
+		mu      *sync.RWMutex
+		config  Config
+	}
+
+	// Stats is a snapshot of the client statistics. Like kafka.ReaderStats and
+	// kafka.WriterStats, counters are deltas since the previous call.
+	Stats struct {
+		Readers   map[string]kafka.ReaderStats // per subscribed topic
+		Writer    kafka.WriterStats
+		Published map[string]int64 // messages published per topic
 	}
 
 	multiConn struct {
@@ -100,8 +148,9 @@ func New(conf *Config) *kafkaClient {
 		return nil
 	}
 	client := &kafkaClient{
-		config: *conf,
-		mu:     &sync.RWMutex{},
+		config:    *conf,
+		mu:        &sync.RWMutex{},
+		published: make(map[string]int64),
 	}
 	ctx := context.Background()
 	if err := client.initialize(ctx); err != nil {
@@ -122,34 +171,19 @@ func (k *kafkaClient) initialize(ctx context.Context) error {
 	k.dialer = dialer
 	k.conn = &multiConn{conns: conns, dialer: dialer}
 	k.writer = createKafkaWriter(&k.config, dialer)
+	k.admin = newAdminClient(&k.config, dialer)
 	k.reader = make(map[string]Reader)
 	return nil
 }
 
 func (k *kafkaClient) getNewReader(topic string) Reader {
-	return kafka.NewReader(kafka.ReaderConfig{
-		GroupID:     k.config.ConsumerGroupID,
-		Brokers:     k.config.Brokers,
-		Topic:       topic,
-		MinBytes:    10e3,
-		MaxBytes:    10e6,
-		Dialer:      k.dialer,
-		StartOffset: int64(k.config.OffSet),
-	})
-}
-
-func (k *kafkaClient) DeleteTopic(_ context.Context, name string) error {
-	return k.conn.DeleteTopics(name)
+	return kafka.NewReader(readerConfig(&k.config, k.dialer, topic))
 }
 
 func (k *kafkaClient) Controller() (kafka.Broker, error) {
 	return k.conn.Controller()
 }
 
-func (k *kafkaClient) CreateTopic(_ context.Context, name string) error {
-	return k.conn.CreateTopics(kafka.TopicConfig{Topic: name, NumPartitions: 1, ReplicationFactor: 1})
-}
-
 func (m *multiConn) Controller() (kafka.Broker, error) {
 	if len(m.conns) == 0 {
 		return kafka.Broker{}, errNoActiveConnections
@@ -166,6 +200,23 @@ func (m *multiConn) Controller() (kafka.Broker, error) {
 	return kafka.Broker{}, errNoActiveConnections
 }
 
+func (m *multiConn) ReadPartitions(topics ...string) ([]kafka.Partition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	err := errNoActiveConnections
+	for _, conn := range m.conns {
+		if conn == nil {
+			continue
+		}
+		var partitions []kafka.Partition
+		if partitions, err = conn.ReadPartitions(topics...); err == nil {
+			return partitions, nil
+		}
+	}
+	return nil, err
+}
+
 func (m *multiConn) CreateTopics(topics ...kafka.TopicConfig) error {
 	controller, err := m.Controller()
 	if err != nil {
@@ -245,6 +296,12 @@ func validateConfigs(conf *Config) error {
 	if err := validateTLSConfigs(conf); err != nil {
 		return err
 	}
+	if err := validateReaderConfigs(conf); err != nil {
+		return err
+	}
+	if err := validateWriterConfigs(conf); err != nil {
+		return err
+	}
 	return validateSecurityProtocol(conf)
 }
 
@@ -266,7 +323,7 @@ func validateRequiredFields(conf *Config) error {
 
 func (k *kafkaClient) retryConnect(ctx context.Context) {
 	for {
-		time.Sleep(defaultRetryTimeout)
+		time.Sleep(k.retryTimeout())
 		if err := k.initialize(ctx); err != nil {
 			brokers := k.config.Brokers
 			if len(brokers) == 1 {
@@ -281,11 +338,17 @@ func (k *kafkaClient) retryConnect(ctx context.Context) {
 }
 
 func (k *kafkaClient) isConnected() bool {
+	return k.Ping(context.Background()) == nil
+}
+
+// Ping reports whether the cluster controller can be reached through one of
+// the broker connections.
+func (k *kafkaClient) Ping(_ context.Context) error {
 	if k.conn == nil {
-		return false
+		return errClientNotConnected
 	}
 	_, err := k.conn.Controller()
-	return err == nil
+	return err
 }
 
 func setupDialer(conf *Config) (*kafka.Dialer, error) {
@@ -329,12 +392,20 @@ func connectToBrokers(ctx context.Context, brokers []string, dialer *kafka.Diale
 }
 
 func createKafkaWriter(conf *Config, dialer *kafka.Dialer) Writer {
+	// the codec and balancer names were checked by validateWriterConfigs
+	codec, _ := compressionCodec(conf.Compression)
+	fallback, _ := balancer(conf.Balancer)
+
 	return kafka.NewWriter(kafka.WriterConfig{
-		Brokers:      conf.Brokers,
-		Dialer:       dialer,
-		BatchSize:    conf.BatchSize,
-		BatchBytes:   conf.BatchBytes,
-		BatchTimeout: time.Duration(conf.BatchTimeout),
+		Brokers:          conf.Brokers,
+		Dialer:           dialer,
+		BatchSize:        conf.BatchSize,
+		BatchBytes:       conf.BatchBytes,
+		BatchTimeout:     time.Duration(conf.BatchTimeout),
+		Balancer:         newPartitionBalancer(fallback),
+		CompressionCodec: codec,
+		// 0 lets the writer default to all replicas
+		RequiredAcks: requiredAcks(conf),
 	})
 }
 
@@ -412,20 +483,30 @@ func validateTLSConfigs(conf *Config) error {
 	return nil
 }
 
-func (k *kafkaClient) Publish(parentCtx context.Context, topic string, message []byte) error {
-	ctx, span := otel.GetTracerProvider().Tracer("gokp").Start(parentCtx, "kafka-publish")
-	defer span.End()
-	if k.writer == nil || topic == "" {
+func (k *kafkaClient) Publish(ctx context.Context, topic string, message []byte) error {
+	return k.PublishMessage(ctx, OutgoingMessage{Topic: topic, Value: message})
+}
+
+// PublishMessage publishes msg in a producer span whose trace context is
+// propagated in the message headers.
+func (k *kafkaClient) PublishMessage(parentCtx context.Context, msg OutgoingMessage) (err error) {
+	kmsg := k.kafkaMessage(msg)
+	ctx, span := startProducerSpan(parentCtx, &kmsg)
+	defer func() { endSpan(span, err) }()
+
+	if k.writer == nil || msg.Topic == "" {
 		return errPublisherNotConfigured
 	}
-	err := k.writer.WriteMessages(ctx, kafka.Message{
-		Topic: topic,
-		Value: message,
-		Time:  time.Now(),
-	})
+	if err = k.autoCreateTopics(ctx, msg.Topic); err != nil {
+		return err
+	}
+	err = k.write(ctx, kmsg)
 	if err != nil {
 		return err
 	}
+	k.mu.Lock()
+	k.published[msg.Topic]++
+	k.mu.Unlock()
 	var hostName string
 	if len(k.config.Brokers) > 1 {
 		hostName = messageMultipleBrokers
@@ -436,17 +517,49 @@ func (k *kafkaClient) Publish(parentCtx context.Context, topic string, message [
 	return nil
 }
 
+func (k *kafkaClient) Forward(ctx context.Context, topic string, msg *Message, headers map[string]string) error {
+	if k.writer == nil || topic == "" {
+		return errPublisherNotConfigured
+	}
+	if err := k.autoCreateTopics(ctx, topic); err != nil {
+		return err
+	}
+
+	err := k.write(ctx, kafka.Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: mergeHeaders(msg.raw.Headers, headers),
+		Time:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.published[topic]++
+	k.mu.Unlock()
+	return nil
+}
+
+// write writes msgs through the PublishPolicy of the config, if any.
+func (k *kafkaClient) write(ctx context.Context, msgs ...kafka.Message) error {
+	if k.config.PublishPolicy == nil {
+		return k.writer.WriteMessages(ctx, msgs...)
+	}
+	return k.config.PublishPolicy.Execute(ctx, func(ctx context.Context) error {
+		return k.writer.WriteMessages(ctx, msgs...)
+	})
+}
+
 func (k *kafkaClient) Subscribe(parentCtx context.Context, topic string) (*Message, error) {
 	if !k.isConnected() {
-		time.Sleep(defaultRetryTimeout)
+		time.Sleep(k.retryTimeout())
 		return nil, errClientNotConnected
 	}
 	if k.config.ConsumerGroupID == "" {
 		return &Message{}, ErrConsumerGroupNotProvided
 	}
 
-	ctx, span := otel.GetTracerProvider().Tracer("go-kp").Start(parentCtx, "kafka-subscribe")
-	defer span.End()
 	k.mu.Lock()
 	if k.reader == nil {
 		k.reader = make(map[string]Reader)
@@ -456,25 +569,55 @@ func (k *kafkaClient) Subscribe(parentCtx context.Context, topic string) (*Messa
 	}
 	k.mu.Unlock()
 	reader := k.reader[topic]
-	msg, err := reader.FetchMessage(ctx)
+	msg, err := reader.FetchMessage(parentCtx)
 	if err != nil {
 		return nil, err
 	}
+
+	// the span of the producer, if any, is the parent of the consumer span
+	ctx, span := startConsumerSpan(parentCtx, msg, k.config.ConsumerGroupID)
+
 	m := NewMessage(ctx, msg)
-	m.Value = msg.Value
+	m.span = span
 	m.Topic = topic
+	m.ConsumerGroup = k.config.ConsumerGroupID
 	m.Committer = newKafkaMessage(&msg, k.reader[topic])
 
 	return m, err
 }
 
-func (k *kafkaClient) Close() (err error) {
-	for _, r := range k.reader {
-		err = errors.Join(err, r.Close())
+// Stats returns the reader statistics of every subscribed topic, the writer
+// statistics and the number of messages published per topic since the last call.
+func (k *kafkaClient) Stats() Stats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	stats := Stats{
+		Readers:   make(map[string]kafka.ReaderStats, len(k.reader)),
+		Published: k.published,
+	}
+	k.published = make(map[string]int64)
+
+	for topic, r := range k.reader {
+		stats.Readers[topic] = r.Stats()
+	}
+	if k.writer != nil {
+		stats.Writer = k.writer.Stats()
 	}
+
+	return stats
+}
+
+// Close flushes pending writes, then closes the readers and the broker connections.
+func (k *kafkaClient) Close() (err error) {
 	if k.writer != nil {
 		err = errors.Join(err, k.writer.Close())
 	}
+	k.mu.Lock()
+	for _, r := range k.reader {
+		err = errors.Join(err, r.Close())
+	}
+	k.mu.Unlock()
 	if k.conn != nil {
 		err = errors.Join(err, k.conn.Close())
 	}
@@ -0,0 +1,406 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var errClientClosed = errors.New("kafka client closed")
+
+// MemoryBroker is an in-memory Kafka cluster for tests and local development.
+// Its clients implement Client without a running broker: topics have
+// partitions, messages keep their keys and headers, and consumer groups
+// resume from their committed offsets.
+//
+// Topics are created with one partition when first written to or consumed;
+// create them with CreateTopics for more partitions.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	topics   map[string][][]kafka.Message // messages per partition
+	balancer *partitionBalancer
+	// committed holds the next offset to consume per group and partition.
+	committed map[groupPartition]int64
+	// written is closed, and replaced, whenever messages are written.
+	written chan struct{}
+}
+
+type groupPartition struct {
+	group     string
+	topic     string
+	partition int
+}
+
+// memoryClient is a member of a consumer group of a MemoryBroker. It consumes
+// every partition of the topics it subscribes to, from the committed offsets
+// of its group; messages fetched and not committed are delivered again to the
+// next client of the group, as after a restart.
+type memoryClient struct {
+	broker *MemoryBroker
+	group  string
+	// next holds the next offset to fetch per partition, and cursor the
+	// partition to look at first per topic.
+	next   map[groupPartition]int64
+	cursor map[string]int
+	closed bool
+}
+
+// NewMemoryBroker returns an empty in-memory cluster.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		topics:    make(map[string][][]kafka.Message),
+		balancer:  newPartitionBalancer(nil),
+		committed: make(map[groupPartition]int64),
+		written:   make(chan struct{}),
+	}
+}
+
+// Client returns a client of the broker consuming as consumer group group.
+func (b *MemoryBroker) Client(group string) Client {
+	return &memoryClient{
+		broker: b,
+		group:  group,
+		next:   make(map[groupPartition]int64),
+		cursor: make(map[string]int),
+	}
+}
+
+// Messages returns the messages of topic, by partition and offset.
+func (b *MemoryBroker) Messages(topic string) []*Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []*Message
+	for _, partition := range b.topics[topic] {
+		for _, msg := range partition {
+			messages = append(messages, NewMessage(context.Background(), msg))
+		}
+	}
+	return messages
+}
+
+// CommittedOffset returns the next offset group will consume from partition
+// of topic, 0 if it has committed nothing.
+func (b *MemoryBroker) CommittedOffset(group, topic string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.committed[groupPartition{group: group, topic: topic, partition: partition}]
+}
+
+// topic returns the partitions of name, creating the topic with one partition
+// if needed. b.mu must be held.
+func (b *MemoryBroker) topic(name string) [][]kafka.Message {
+	partitions, ok := b.topics[name]
+	if !ok {
+		partitions = make([][]kafka.Message, 1)
+		b.topics[name] = partitions
+	}
+	return partitions
+}
+
+// write appends messages to their partitions. b.mu must be held.
+func (b *MemoryBroker) write(messages ...kafka.Message) {
+	for _, msg := range messages {
+		partitions := b.topic(msg.Topic)
+		ids := make([]int, len(partitions))
+		for i := range ids {
+			ids[i] = i
+		}
+
+		msg.Partition = b.balancer.Balance(msg, ids...)
+		msg.Offset = int64(len(partitions[msg.Partition]))
+		msg.WriterData = nil
+		if msg.Time.IsZero() {
+			msg.Time = time.Now()
+		}
+		partitions[msg.Partition] = append(partitions[msg.Partition], msg)
+	}
+
+	close(b.written)
+	b.written = make(chan struct{})
+}
+
+func (b *MemoryBroker) commit(group string, msg kafka.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.commitLocked(group, msg)
+}
+
+// commitLocked commits msg for group. b.mu must be held.
+func (b *MemoryBroker) commitLocked(group string, msg kafka.Message) {
+	key := groupPartition{group: group, topic: msg.Topic, partition: msg.Partition}
+	if msg.Offset+1 > b.committed[key] {
+		b.committed[key] = msg.Offset + 1
+	}
+}
+
+func (c *memoryClient) Publish(ctx context.Context, topic string, message []byte) error {
+	return c.PublishMessage(ctx, OutgoingMessage{Topic: topic, Value: message})
+}
+
+func (c *memoryClient) PublishMessage(ctx context.Context, msg OutgoingMessage) (err error) {
+	kmsg := msg.kafkaMessage()
+	_, span := startProducerSpan(ctx, &kmsg)
+	defer func() { endSpan(span, err) }()
+
+	if msg.Topic == "" {
+		return errPublisherNotConfigured
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
+	c.broker.write(kmsg)
+	return nil
+}
+
+func (c *memoryClient) Forward(_ context.Context, topic string, msg *Message, headers map[string]string) error {
+	if topic == "" {
+		return errPublisherNotConfigured
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
+	c.broker.write(kafka.Message{
+		Topic:   topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: mergeHeaders(msg.raw.Headers, headers),
+		Time:    time.Now(),
+	})
+	return nil
+}
+
+// Transaction writes the messages of fn and commits the offsets it marks
+// atomically: other clients see all of them or none.
+func (c *memoryClient) Transaction(ctx context.Context, fn func(tx Tx) error) error {
+	tx := &batchTx{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	batch := make([]kafka.Message, len(tx.messages))
+	for i, msg := range tx.messages {
+		if msg.Topic == "" {
+			return errPublisherNotConfigured
+		}
+		batch[i] = msg.kafkaMessage()
+		_, span := startProducerSpan(ctx, &batch[i])
+		span.End()
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
+	c.broker.write(batch...)
+	for _, msg := range tx.consumed {
+		c.broker.commitLocked(c.group, msg.raw)
+	}
+	return nil
+}
+
+// Subscribe returns the next message of topic, waiting for one until ctx is done.
+func (c *memoryClient) Subscribe(ctx context.Context, topic string) (*Message, error) {
+	for {
+		c.broker.mu.Lock()
+		if c.closed {
+			c.broker.mu.Unlock()
+			return nil, errClientClosed
+		}
+		msg, ok := c.fetch(topic)
+		written := c.broker.written
+		c.broker.mu.Unlock()
+
+		if ok {
+			msgCtx, span := startConsumerSpan(ctx, msg, c.group)
+
+			m := NewMessage(msgCtx, msg)
+			m.span = span
+			m.ConsumerGroup = c.group
+			m.Committer = &memoryCommitter{broker: c.broker, group: c.group, msg: msg}
+			return m, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-written:
+		}
+	}
+}
+
+// fetch returns the next message of topic, looking at its partitions in turn.
+// c.broker.mu must be held.
+func (c *memoryClient) fetch(topic string) (kafka.Message, bool) {
+	partitions := c.broker.topic(topic)
+	for i := range partitions {
+		p := (c.cursor[topic] + i) % len(partitions)
+		key := groupPartition{group: c.group, topic: topic, partition: p}
+
+		next, ok := c.next[key]
+		if !ok {
+			next = c.broker.committed[key]
+		}
+		if next >= int64(len(partitions[p])) {
+			continue
+		}
+
+		c.next[key] = next + 1
+		c.cursor[topic] = p + 1
+		return partitions[p][next], true
+	}
+	return kafka.Message{}, false
+}
+
+type memoryCommitter struct {
+	broker *MemoryBroker
+	group  string
+	msg    kafka.Message
+}
+
+func (mc *memoryCommitter) Commit() {
+	mc.broker.commit(mc.group, mc.msg)
+}
+
+func (c *memoryClient) CreateTopic(ctx context.Context, name string) error {
+	return c.CreateTopics(ctx, TopicConfig{Name: name})
+}
+
+func (c *memoryClient) CreateTopics(_ context.Context, topics ...TopicConfig) error {
+	configs := make([]kafka.TopicConfig, len(topics))
+	for i, t := range topics {
+		tc, err := t.kafkaTopicConfig()
+		if err != nil {
+			return err
+		}
+		configs[i] = tc
+	}
+
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	for _, tc := range configs {
+		if _, exists := c.broker.topics[tc.Topic]; !exists {
+			c.broker.topics[tc.Topic] = make([][]kafka.Message, tc.NumPartitions)
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) EnsureTopics(ctx context.Context, names ...string) error {
+	topics := make([]TopicConfig, 0, len(names))
+	for _, name := range names {
+		topics = append(topics, TopicConfig{Name: name})
+	}
+	return c.CreateTopics(ctx, topics...)
+}
+
+func (c *memoryClient) DescribeTopics(_ context.Context, names ...string) ([]TopicDescription, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	descriptions := make([]TopicDescription, 0, len(names))
+	for _, name := range names {
+		partitions, ok := c.broker.topics[name]
+		if !ok {
+			return nil, fmt.Errorf("topic %s: %w", name, kafka.UnknownTopicOrPartition)
+		}
+
+		d := TopicDescription{Name: name}
+		for i := range partitions {
+			d.Partitions = append(d.Partitions, PartitionDescription{ID: i, Replicas: []int{0}, ISR: []int{0}})
+		}
+		descriptions = append(descriptions, d)
+	}
+	sort.Slice(descriptions, func(i, j int) bool { return descriptions[i].Name < descriptions[j].Name })
+	return descriptions, nil
+}
+
+func (c *memoryClient) ListTopics(_ context.Context) ([]string, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	topics := make([]string, 0, len(c.broker.topics))
+	for name := range c.broker.topics {
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+func (c *memoryClient) AlterPartitions(_ context.Context, topic string, count int) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	partitions, ok := c.broker.topics[topic]
+	if !ok {
+		return fmt.Errorf("topic %s: %w", topic, kafka.UnknownTopicOrPartition)
+	}
+	if count <= len(partitions) {
+		return fmt.Errorf("topic %s has %d partitions: %w", topic, len(partitions), kafka.InvalidPartitionNumber)
+	}
+
+	c.broker.topics[topic] = append(partitions, make([][]kafka.Message, count-len(partitions))...)
+	return nil
+}
+
+func (c *memoryClient) DeleteTopic(_ context.Context, name string) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if _, ok := c.broker.topics[name]; !ok {
+		return fmt.Errorf("topic %s: %w", name, kafka.UnknownTopicOrPartition)
+	}
+	delete(c.broker.topics, name)
+	for key := range c.broker.committed {
+		if key.topic == name {
+			delete(c.broker.committed, key)
+		}
+	}
+	for key := range c.next {
+		if key.topic == name {
+			delete(c.next, key)
+		}
+	}
+	return nil
+}
+
+// Ping reports an error once the client is closed.
+func (c *memoryClient) Ping(_ context.Context) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	if c.closed {
+		return errClientClosed
+	}
+	return nil
+}
+
+// Close closes the client and wakes up its pending Subscribe calls. The
+// broker and its other clients are not affected.
+func (c *memoryClient) Close() error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+
+	c.closed = true
+	close(c.broker.written)
+	c.broker.written = make(chan struct{})
+	return nil
+}
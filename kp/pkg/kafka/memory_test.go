@@ -0,0 +1,208 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func subscribeWithin(t *testing.T, c Client, topic string) *Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	msg, err := c.Subscribe(ctx, topic)
+	if err != nil {
+		t.Fatalf("subscribe %s: %v", topic, err)
+	}
+	return msg
+}
+
+func TestMemoryBrokerPublishSubscribe(t *testing.T) {
+	broker := NewMemoryBroker()
+	producer := broker.Client("")
+	consumer := broker.Client("billing")
+
+	partition := 1
+	if err := producer.CreateTopics(context.Background(), TopicConfig{Name: "orders", Partitions: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := producer.PublishMessage(context.Background(), OutgoingMessage{
+		Topic:     "orders",
+		Key:       []byte("customer-1"),
+		Value:     []byte(`{"id":1}`),
+		Headers:   map[string]string{HeaderTransactionID: "tx-1"},
+		Partition: &partition,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg := subscribeWithin(t, consumer, "orders")
+	if string(msg.Key) != "customer-1" || string(msg.Value) != `{"id":1}` {
+		t.Errorf("unexpected key %q and value %q", msg.Key, msg.Value)
+	}
+	if msg.Partition != 1 || msg.Offset != 0 || msg.ConsumerGroup != "billing" {
+		t.Errorf("unexpected partition %d, offset %d and group %q", msg.Partition, msg.Offset, msg.ConsumerGroup)
+	}
+	if msg.Header(HeaderTransactionID) != "tx-1" || msg.TransactionId() != "tx-1" {
+		t.Errorf("expected the transaction header, got %v", msg.Headers())
+	}
+}
+
+func TestMemoryBrokerSubscribeWaitsForMessages(t *testing.T) {
+	broker := NewMemoryBroker()
+	client := broker.Client("billing")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = client.Publish(context.Background(), "orders", []byte("late"))
+	}()
+	if msg := subscribeWithin(t, client, "orders"); string(msg.Value) != "late" {
+		t.Errorf("expected the late message, got %q", msg.Value)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.Subscribe(ctx, "orders"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
+
+func TestMemoryBrokerConsumerGroupsResumeFromCommits(t *testing.T) {
+	broker := NewMemoryBroker()
+	producer := broker.Client("")
+	for _, v := range []string{"a", "b", "c"} {
+		if err := producer.Publish(context.Background(), "orders", []byte(v)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	first := broker.Client("billing")
+	subscribeWithin(t, first, "orders").Commit()
+	subscribeWithin(t, first, "orders") // fetched, not committed
+	if got := broker.CommittedOffset("billing", "orders", 0); got != 1 {
+		t.Fatalf("expected committed offset 1, got %d", got)
+	}
+
+	// a restarted member resumes after the last commit
+	if msg := subscribeWithin(t, broker.Client("billing"), "orders"); string(msg.Value) != "b" {
+		t.Errorf("expected b to be delivered again, got %q", msg.Value)
+	}
+	// another group reads from the beginning
+	if msg := subscribeWithin(t, broker.Client("shipping"), "orders"); string(msg.Value) != "a" {
+		t.Errorf("expected a for a new group, got %q", msg.Value)
+	}
+}
+
+func TestMemoryBrokerKeysStayOnPartition(t *testing.T) {
+	broker := NewMemoryBroker()
+	client := broker.Client("")
+	if err := client.CreateTopics(context.Background(), TopicConfig{Name: "orders", Partitions: 4}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := client.PublishMessage(context.Background(), OutgoingMessage{Topic: "orders", Key: []byte("k"), Value: []byte("v")}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	messages := broker.Messages("orders")
+	for _, msg := range messages {
+		if msg.Partition != messages[0].Partition {
+			t.Fatalf("expected every message of a key on one partition, got %d and %d", messages[0].Partition, msg.Partition)
+		}
+	}
+	if last := messages[len(messages)-1]; last.Offset != 4 {
+		t.Errorf("expected offsets to increase per partition, got %d", last.Offset)
+	}
+}
+
+func TestMemoryBrokerTransaction(t *testing.T) {
+	broker := NewMemoryBroker()
+	client := broker.Client("billing")
+	if err := client.Publish(context.Background(), "orders", []byte("in")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	in := subscribeWithin(t, client, "orders")
+
+	err := client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("invoices", []byte("out"))
+		tx.CommitOffset(in)
+		return errors.New("abort")
+	})
+	if err == nil || len(broker.Messages("invoices")) != 0 || broker.CommittedOffset("billing", "orders", 0) != 0 {
+		t.Fatalf("expected an aborted transaction to write and commit nothing")
+	}
+
+	err = client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("invoices", []byte("out"))
+		tx.CommitOffset(in)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(broker.Messages("invoices")) != 1 || broker.CommittedOffset("billing", "orders", 0) != 1 {
+		t.Errorf("expected the message written and the offset committed")
+	}
+}
+
+func TestMemoryBrokerAdmin(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryBroker().Client("")
+
+	if err := client.CreateTopics(ctx, TopicConfig{Name: "orders", Partitions: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.AlterPartitions(ctx, "orders", 1); !errors.Is(err, kafka.InvalidPartitionNumber) {
+		t.Errorf("expected partitions not to be removed, got %v", err)
+	}
+	if err := client.AlterPartitions(ctx, "orders", 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	topics, err := client.DescribeTopics(ctx, "orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(topics) != 1 || len(topics[0].Partitions) != 3 {
+		t.Errorf("expected 3 partitions, got %+v", topics)
+	}
+
+	if err := client.DeleteTopic(ctx, "orders"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names, _ := client.ListTopics(ctx); len(names) != 0 {
+		t.Errorf("expected no topics, got %v", names)
+	}
+}
+
+func TestMemoryClientClose(t *testing.T) {
+	client := NewMemoryBroker().Client("billing")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Subscribe(context.Background(), "orders")
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if err := client.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errClientClosed) {
+			t.Errorf("expected errClientClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to wake up Subscribe")
+	}
+}
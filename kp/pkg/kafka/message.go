@@ -6,8 +6,9 @@ import (
 	"errors"
 	"net/url"
 	"reflect"
+	"slices"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
@@ -16,10 +17,18 @@ import (
 
 type Client interface {
 	Publish(ctx context.Context, topic string, message []byte) error
+	PublishMessage(ctx context.Context, msg OutgoingMessage) error
+	// Subscribe returns the next message of topic. Its consumer span stays
+	// open until the caller ends it with Message.EndSpan.
 	Subscribe(ctx context.Context, topic string) (*Message, error)
+	// Forward publishes the key, value and headers of a consumed message to topic,
+	// with headers added or overridden.
+	Forward(ctx context.Context, topic string, msg *Message, headers map[string]string) error
+	// Transaction publishes the messages of fn in one batch and commits the
+	// consumed offsets it marks, unless fn fails.
+	Transaction(ctx context.Context, fn func(tx Tx) error) error
 
-	CreateTopic(context context.Context, name string) error
-	DeleteTopic(context context.Context, name string) error
+	Admin
 
 	Close() error
 }
@@ -33,15 +42,24 @@ var errNotPointer = errors.New("input should be a pointer to a variable")
 type Message struct {
 	ctx context.Context
 
-	Topic    string
-	Value    []byte
-	MetaData any
+	Topic         string
+	Partition     int
+	Offset        int64
+	Key           []byte
+	Value         []byte
+	Time          time.Time // time the message was produced
+	ConsumerGroup string
+	MetaData      any
 
 	Committer
 
 	TransactionID string
 	SessionID     string
 	RequestID     string
+
+	raw kafka.Message
+	// span is the consumer span of the message, ended by EndSpan.
+	span trace.Span
 }
 
 type MsgConsumer struct {
@@ -68,10 +86,10 @@ func NewMessage(ctx context.Context, msg kafka.Message) *Message {
 	if len(msg.Headers) != 0 {
 		for _, header := range msg.Headers {
 			switch header.Key {
-			case strings.ToLower("X-Transaction-ID"):
+			case HeaderTransactionID:
 				data.Header.Transaction = string(header.Value)
 				extractHeaders = false
-			case strings.ToLower("X-Session-ID"):
+			case HeaderSessionID:
 				data.Header.Session = string(header.Value)
 				extractHeaders = false
 			}
@@ -95,6 +113,13 @@ func NewMessage(ctx context.Context, msg kafka.Message) *Message {
 		TransactionID: data.Header.Transaction,
 		SessionID:     data.Header.Session,
 		RequestID:     traceID,
+		Topic:         msg.Topic,
+		Partition:     msg.Partition,
+		Offset:        msg.Offset,
+		Key:           msg.Key,
+		Value:         msg.Value,
+		Time:          msg.Time,
+		raw:           msg,
 	}
 }
 
@@ -102,9 +127,34 @@ func (m *Message) Context() context.Context {
 	return m.ctx
 }
 
+// EndSpan ends the consumer span started by Subscribe, recording err if it is
+// not nil. Consumers call it once the message has been handled and committed,
+// so that the span covers the handler.
+func (m *Message) EndSpan(err error) {
+	if m.span != nil {
+		endSpan(m.span, err)
+	}
+}
+
+// Param returns the metadata of the message: "topic", "key", "partition",
+// "offset", "timestamp" (RFC 3339) or "consumer_group".
 func (m *Message) Param(p string) string {
-	if p == "topic" {
+	switch p {
+	case "topic":
 		return m.Topic
+	case "key":
+		return string(m.Key)
+	case "partition":
+		return strconv.Itoa(m.Partition)
+	case "offset":
+		return strconv.FormatInt(m.Offset, 10)
+	case "timestamp":
+		if m.Time.IsZero() {
+			return ""
+		}
+		return m.Time.Format(time.RFC3339Nano)
+	case "consumer_group":
+		return m.ConsumerGroup
 	}
 
 	return ""
@@ -141,11 +191,43 @@ func (m *Message) Referer() string {
 func (m *Message) HostName() string {
 	return "" // Kafka messages do not have a hostname like HTTP requests
 }
+
+// Headers returns the Kafka headers of the message. When a key is repeated
+// the last value wins.
 func (m *Message) Headers() map[string]string {
-	return nil // Kafka messages do not have headers like HTTP requests
+	if len(m.raw.Headers) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]string, len(m.raw.Headers))
+	for _, h := range m.raw.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return headers
 }
+
+// Header returns the value of the Kafka header key, or "" if it is not set.
 func (m *Message) Header(key string) string {
-	return "" // Kafka messages do not have headers like HTTP requests
+	value := ""
+	for _, h := range m.raw.Headers {
+		if h.Key == key {
+			value = string(h.Value)
+		}
+	}
+	return value
+}
+
+// WithoutHeaders returns a copy of the message without the Kafka headers keys,
+// e.g. to forward it without them.
+func (m *Message) WithoutHeaders(keys ...string) *Message {
+	c := *m
+	c.raw.Headers = make([]kafka.Header, 0, len(m.raw.Headers))
+	for _, h := range m.raw.Headers {
+		if !slices.Contains(keys, h.Key) {
+			c.raw.Headers = append(c.raw.Headers, h)
+		}
+	}
+	return &c
 }
 
 func (m *Message) Query() url.Values {
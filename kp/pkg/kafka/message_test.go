@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMessageMetadata(t *testing.T) {
+	produced := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	msg := NewMessage(context.Background(), kafka.Message{
+		Topic:     "orders",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("customer-1"),
+		Value:     []byte(`{"id":1}`),
+		Time:      produced,
+		Headers: []kafka.Header{
+			{Key: "x-source", Value: []byte("web")},
+			{Key: "x-source", Value: []byte("mobile")},
+			{Key: HeaderTransactionID, Value: []byte("tx-1")},
+		},
+	})
+	msg.ConsumerGroup = "billing"
+
+	params := map[string]string{
+		"topic":          "orders",
+		"key":            "customer-1",
+		"partition":      "3",
+		"offset":         "42",
+		"timestamp":      "2024-05-01T10:00:00Z",
+		"consumer_group": "billing",
+	}
+	for p, want := range params {
+		if got := msg.Param(p); got != want {
+			t.Errorf("Param(%q) = %q, want %q", p, got, want)
+		}
+	}
+
+	headers := msg.Headers()
+	if len(headers) != 2 || headers["x-source"] != "mobile" || headers[HeaderTransactionID] != "tx-1" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if got := msg.Header("x-source"); got != "mobile" {
+		t.Errorf("expected the last header value, got %q", got)
+	}
+	if msg.TransactionID != "tx-1" {
+		t.Errorf("expected transaction id from headers, got %q", msg.TransactionID)
+	}
+
+	if empty := NewMessage(context.Background(), kafka.Message{}); empty.Headers() != nil || empty.Param("timestamp") != "" {
+		t.Errorf("expected no headers and no timestamp, got %v %q", empty.Headers(), empty.Param("timestamp"))
+	}
+}
+
+func TestMessageWithoutHeaders(t *testing.T) {
+	msg := NewMessage(context.Background(), kafka.Message{
+		Topic: "orders",
+		Headers: []kafka.Header{
+			{Key: "x-retry-after", Value: []byte("1")},
+			{Key: "x-source", Value: []byte("web")},
+			{Key: "x-retry-after", Value: []byte("2")},
+		},
+	})
+
+	stripped := msg.WithoutHeaders("x-retry-after")
+
+	if headers := stripped.Headers(); len(headers) != 1 || headers["x-source"] != "web" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if msg.Header("x-retry-after") != "2" || stripped.Topic != "orders" {
+		t.Errorf("expected the original message to be kept, got %v", msg.Headers())
+	}
+}
@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/compress"
+)
+
+const (
+	defaultMinBytes = 10e3
+	defaultMaxBytes = 10e6
+)
+
+// readerConfig returns the configuration of the reader of topic. A reader
+// without a consumer group reads conf.Partition.
+func readerConfig(conf *Config, dialer *kafka.Dialer, topic string) kafka.ReaderConfig {
+	rc := kafka.ReaderConfig{
+		GroupID:           conf.ConsumerGroupID,
+		Brokers:           conf.Brokers,
+		Topic:             topic,
+		MinBytes:          conf.MinBytes,
+		MaxBytes:          conf.MaxBytes,
+		Dialer:            dialer,
+		StartOffset:       int64(conf.OffSet),
+		SessionTimeout:    conf.SessionTimeout,
+		HeartbeatInterval: conf.HeartbeatInterval,
+		CommitInterval:    conf.CommitInterval,
+	}
+	if rc.MinBytes == 0 {
+		rc.MinBytes = defaultMinBytes
+	}
+	if rc.MaxBytes == 0 {
+		rc.MaxBytes = defaultMaxBytes
+	}
+	if conf.ConsumerGroupID == "" {
+		rc.Partition = conf.Partition
+	}
+	// the names were checked by validateReaderConfigs
+	rc.IsolationLevel, _ = isolationLevel(conf.IsolationLevel)
+	if strategy, _ := groupBalancer(conf.RebalanceStrategy); strategy != nil {
+		rc.GroupBalancers = []kafka.GroupBalancer{strategy}
+	}
+	return rc
+}
+
+func validateReaderConfigs(conf *Config) error {
+	if conf.Partition < 0 {
+		return fmt.Errorf("partition must not be negative: %w", errInvalidReaderConfig)
+	}
+	if conf.Partition != 0 && conf.ConsumerGroupID != "" {
+		return fmt.Errorf("partition %d cannot be set with consumer group %q, which assigns the partitions: %w",
+			conf.Partition, conf.ConsumerGroupID, errInvalidReaderConfig)
+	}
+	if conf.MinBytes < 0 || conf.MaxBytes < 0 {
+		return fmt.Errorf("min and max bytes must not be negative: %w", errInvalidReaderConfig)
+	}
+	if conf.MinBytes > 0 && conf.MaxBytes > 0 && conf.MinBytes > conf.MaxBytes {
+		return fmt.Errorf("min bytes %d greater than max bytes %d: %w", conf.MinBytes, conf.MaxBytes, errInvalidReaderConfig)
+	}
+	if conf.RetryTimeout < 0 || conf.SessionTimeout < 0 || conf.HeartbeatInterval < 0 || conf.CommitInterval < 0 {
+		return fmt.Errorf("timeouts and intervals must not be negative: %w", errInvalidReaderConfig)
+	}
+	if conf.SessionTimeout > 0 && conf.HeartbeatInterval >= conf.SessionTimeout {
+		return fmt.Errorf("heartbeat interval %v must be lower than session timeout %v: %w",
+			conf.HeartbeatInterval, conf.SessionTimeout, errInvalidReaderConfig)
+	}
+	if _, err := isolationLevel(conf.IsolationLevel); err != nil {
+		return err
+	}
+	_, err := groupBalancer(conf.RebalanceStrategy)
+	return err
+}
+
+func validateWriterConfigs(conf *Config) error {
+	acks, err := parseRequiredAcks(conf.RequiredAcks)
+	if err != nil {
+		return err
+	}
+	if conf.Idempotent && acks != 0 && acks != kafka.RequireAll {
+		return fmt.Errorf("idempotent publishing requires all acks, got %q: %w", conf.RequiredAcks, errInvalidWriterConfig)
+	}
+	if _, err := compressionCodec(conf.Compression); err != nil {
+		return err
+	}
+	_, err = balancer(conf.Balancer)
+	return err
+}
+
+func (k *kafkaClient) retryTimeout() time.Duration {
+	if k.config.RetryTimeout > 0 {
+		return k.config.RetryTimeout
+	}
+	return defaultRetryTimeout
+}
+
+func requiredAcks(conf *Config) int {
+	if conf.Idempotent {
+		return int(kafka.RequireAll)
+	}
+	acks, _ := parseRequiredAcks(conf.RequiredAcks)
+	return int(acks)
+}
+
+// parseRequiredAcks returns 0 for an empty name.
+func parseRequiredAcks(name string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return 0, nil
+	case "all", "-1":
+		return kafka.RequireAll, nil
+	case "one", "1":
+		return kafka.RequireOne, nil
+	case "none", "0":
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("unsupported required acks %q: %w", name, errInvalidWriterConfig)
+	}
+}
+
+// compressionCodec returns nil, no compression, for an empty name.
+func compressionCodec(name string) (kafka.CompressionCodec, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return nil, nil
+	case "gzip":
+		return compress.Gzip.Codec(), nil
+	case "snappy":
+		return compress.Snappy.Codec(), nil
+	case "lz4":
+		return compress.Lz4.Codec(), nil
+	case "zstd":
+		return compress.Zstd.Codec(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q: %w", name, errInvalidWriterConfig)
+	}
+}
+
+// balancer returns nil, the default of newPartitionBalancer, for an empty name.
+func balancer(name string) (kafka.Balancer, error) {
+	switch strings.ToLower(name) {
+	case "", "hash":
+		return nil, nil
+	case "round_robin":
+		return &kafka.RoundRobin{}, nil
+	case "least_bytes":
+		return &kafka.LeastBytes{}, nil
+	case "crc32":
+		return kafka.CRC32Balancer{}, nil
+	case "murmur2":
+		return kafka.Murmur2Balancer{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported balancer %q: %w", name, errInvalidWriterConfig)
+	}
+}
+
+// groupBalancer returns nil, the reader default, for an empty name.
+func groupBalancer(name string) (kafka.GroupBalancer, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return nil, nil
+	case "range":
+		return kafka.RangeGroupBalancer{}, nil
+	case "roundrobin", "round_robin":
+		return kafka.RoundRobinGroupBalancer{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported rebalance strategy %q: %w", name, errInvalidReaderConfig)
+	}
+}
+
+func isolationLevel(name string) (kafka.IsolationLevel, error) {
+	switch strings.ToLower(name) {
+	case "", "read_uncommitted":
+		return kafka.ReadUncommitted, nil
+	case "read_committed":
+		return kafka.ReadCommitted, nil
+	default:
+		return 0, fmt.Errorf("unsupported isolation level %q: %w", name, errInvalidReaderConfig)
+	}
+}
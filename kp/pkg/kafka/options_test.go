@@ -0,0 +1,121 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Brokers:      []string{"localhost:9092"},
+		BatchSize:    DefaultBatchSize,
+		BatchBytes:   DefaultBatchBytes,
+		BatchTimeout: DefaultBatchTimeout,
+	}
+}
+
+func TestReaderConfig(t *testing.T) {
+	conf := validConfig()
+	conf.ConsumerGroupID = "billing"
+	conf.SessionTimeout = 20 * time.Second
+	conf.HeartbeatInterval = 2 * time.Second
+	conf.CommitInterval = time.Second
+	conf.IsolationLevel = "read_committed"
+	conf.RebalanceStrategy = "roundrobin"
+
+	rc := readerConfig(conf, nil, "orders")
+	if rc.MinBytes != defaultMinBytes || rc.MaxBytes != defaultMaxBytes {
+		t.Errorf("expected default fetch sizes, got %d and %d", rc.MinBytes, rc.MaxBytes)
+	}
+	if rc.GroupID != "billing" || rc.Partition != 0 {
+		t.Errorf("expected the consumer group and no partition, got %q and %d", rc.GroupID, rc.Partition)
+	}
+	if rc.SessionTimeout != conf.SessionTimeout || rc.HeartbeatInterval != conf.HeartbeatInterval || rc.CommitInterval != conf.CommitInterval {
+		t.Errorf("expected group timings to be applied, got %+v", rc)
+	}
+	if rc.IsolationLevel != kafka.ReadCommitted {
+		t.Errorf("expected read committed, got %v", rc.IsolationLevel)
+	}
+	if len(rc.GroupBalancers) != 1 || rc.GroupBalancers[0].ProtocolName() != "roundrobin" {
+		t.Errorf("expected the round-robin strategy, got %v", rc.GroupBalancers)
+	}
+}
+
+func TestReaderConfigPartitionWithoutGroup(t *testing.T) {
+	conf := validConfig()
+	conf.Partition = 2
+
+	if rc := readerConfig(conf, nil, "orders"); rc.GroupID != "" || rc.Partition != 2 {
+		t.Errorf("expected partition 2 without a consumer group, got %q and %d", rc.GroupID, rc.Partition)
+	}
+}
+
+func TestSubscribeRequiresConsumerGroup(t *testing.T) {
+	k := newTestClient(&fakeWriter{}, nil)
+	k.config.ConsumerGroupID = ""
+
+	if _, err := k.Subscribe(context.Background(), "orders"); !errors.Is(err, ErrConsumerGroupNotProvided) {
+		t.Errorf("expected ErrConsumerGroupNotProvided, got %v", err)
+	}
+	if len(k.reader) != 0 {
+		t.Errorf("expected no reader without a consumer group, got %v", k.reader)
+	}
+}
+
+func TestValidateConfigs(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   error
+	}{
+		{"valid", func(*Config) {}, nil},
+		{"negative partition", func(c *Config) { c.Partition = -1 }, errInvalidReaderConfig},
+		{"partition with consumer group", func(c *Config) { c.ConsumerGroupID, c.Partition = "billing", 1 }, errInvalidReaderConfig},
+		{"min above max", func(c *Config) { c.MinBytes, c.MaxBytes = 100, 10 }, errInvalidReaderConfig},
+		{"heartbeat above session", func(c *Config) {
+			c.SessionTimeout, c.HeartbeatInterval = time.Second, 2*time.Second
+		}, errInvalidReaderConfig},
+		{"isolation level", func(c *Config) { c.IsolationLevel = "serializable" }, errInvalidReaderConfig},
+		{"rebalance strategy", func(c *Config) { c.RebalanceStrategy = "sticky" }, errInvalidReaderConfig},
+		{"required acks", func(c *Config) { c.RequiredAcks = "two" }, errInvalidWriterConfig},
+		{"idempotent without all acks", func(c *Config) { c.Idempotent, c.RequiredAcks = true, "one" }, errInvalidWriterConfig},
+		{"compression", func(c *Config) { c.Compression = "brotli" }, errInvalidWriterConfig},
+		{"balancer", func(c *Config) { c.Balancer = "sticky" }, errInvalidWriterConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := validConfig()
+			tt.modify(conf)
+			if err := validateConfigs(conf); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCreateKafkaWriter(t *testing.T) {
+	conf := validConfig()
+	conf.RequiredAcks = "one"
+	conf.Compression = "gzip"
+	conf.Balancer = "least_bytes"
+
+	w := createKafkaWriter(conf, nil).(*kafka.Writer)
+	if w.RequiredAcks != kafka.RequireOne {
+		t.Errorf("expected one ack, got %v", w.RequiredAcks)
+	}
+	if w.Compression != kafka.Gzip {
+		t.Errorf("expected gzip compression, got %v", w.Compression)
+	}
+	b, ok := w.Balancer.(*partitionBalancer)
+	if !ok {
+		t.Fatalf("expected the partition balancer, got %T", w.Balancer)
+	}
+	if _, ok := b.fallback.(*kafka.LeastBytes); !ok {
+		t.Errorf("expected least bytes fallback, got %T", b.fallback)
+	}
+}
@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// OutgoingMessage is a message published with PublishMessage.
+type OutgoingMessage struct {
+	Topic string
+	// Key selects the partition when Partition is not set: messages with the
+	// same key go to the same partition and keep their order.
+	Key     []byte
+	Headers map[string]string
+	Value   []byte
+	// Partition, when set, is the partition written to.
+	Partition *int
+	// Time defaults to the time of publishing.
+	Time time.Time
+}
+
+// Headers used to propagate the identifiers of a request through Kafka.
+const (
+	HeaderTransactionID = "x-transaction-id"
+	HeaderSessionID     = "x-session-id"
+	HeaderRequestID     = "x-request-id"
+	// HeaderMessageID identifies a message published in idempotent mode.
+	HeaderMessageID = "x-message-id"
+)
+
+// explicitPartition is carried in kafka.Message.WriterData to let
+// partitionBalancer honour OutgoingMessage.Partition.
+type explicitPartition int
+
+// partitionBalancer writes messages to their explicit partition, if any, and
+// balances the others by key.
+type partitionBalancer struct {
+	fallback kafka.Balancer
+}
+
+func newPartitionBalancer(fallback kafka.Balancer) *partitionBalancer {
+	if fallback == nil {
+		// Hash falls back to round-robin for messages without a key.
+		fallback = &kafka.Hash{}
+	}
+	return &partitionBalancer{fallback: fallback}
+}
+
+func (b *partitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if p, ok := msg.WriterData.(explicitPartition); ok {
+		for _, partition := range partitions {
+			if partition == int(p) {
+				return partition
+			}
+		}
+	}
+	return b.fallback.Balance(msg, partitions...)
+}
+
+func (m OutgoingMessage) kafkaMessage() kafka.Message {
+	msg := kafka.Message{
+		Topic:   m.Topic,
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: mergeHeaders(nil, m.Headers),
+		Time:    m.Time,
+	}
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	if m.Partition != nil {
+		msg.WriterData = explicitPartition(*m.Partition)
+	}
+	return msg
+}
+
+// kafkaMessage converts msg for the writer of the client.
+func (k *kafkaClient) kafkaMessage(msg OutgoingMessage) kafka.Message {
+	m := msg.kafkaMessage()
+	if k.config.Idempotent && msg.Headers[HeaderMessageID] == "" {
+		m.Headers = append(m.Headers, kafka.Header{Key: HeaderMessageID, Value: []byte(uuid.NewString())})
+	}
+	return m
+}
+
+// mergeHeaders returns headers with the values of overrides replacing, or
+// added after, the headers of the same key.
+func mergeHeaders(headers []kafka.Header, overrides map[string]string) []kafka.Header {
+	merged := make([]kafka.Header, 0, len(headers)+len(overrides))
+	for _, h := range headers {
+		if _, ok := overrides[h.Key]; !ok {
+			merged = append(merged, h)
+		}
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		merged = append(merged, kafka.Header{Key: key, Value: []byte(overrides[key])})
+	}
+	return merged
+}
@@ -0,0 +1,94 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sing3demons/go-common-kp/kp/pkg/resilience"
+)
+
+func TestPartitionBalancer(t *testing.T) {
+	b := newPartitionBalancer(nil)
+	partitions := []int{0, 1, 2, 3}
+
+	partition := 2
+	msg := OutgoingMessage{Topic: "orders", Value: []byte("v"), Partition: &partition}.kafkaMessage()
+	for i := 0; i < 5; i++ {
+		if got := b.Balance(msg, partitions...); got != 2 {
+			t.Fatalf("expected explicit partition 2, got %d", got)
+		}
+	}
+
+	keyed := OutgoingMessage{Topic: "orders", Key: []byte("customer-1")}.kafkaMessage()
+	first := b.Balance(keyed, partitions...)
+	for i := 0; i < 5; i++ {
+		if got := b.Balance(keyed, partitions...); got != first {
+			t.Fatalf("expected key to stay on partition %d, got %d", first, got)
+		}
+	}
+
+	// an unknown partition falls back to the key
+	missing := 9
+	msg = OutgoingMessage{Topic: "orders", Key: []byte("customer-1"), Partition: &missing}.kafkaMessage()
+	if got := b.Balance(msg, partitions...); got != first {
+		t.Errorf("expected fallback to partition %d, got %d", first, got)
+	}
+}
+
+func TestOutgoingMessageHeaders(t *testing.T) {
+	msg := OutgoingMessage{
+		Topic:   "orders",
+		Headers: map[string]string{HeaderTransactionID: "tx-1", HeaderSessionID: "s-1"},
+	}.kafkaMessage()
+
+	if msg.Time.IsZero() {
+		t.Error("expected the message time to default to now")
+	}
+
+	consumed := NewMessage(nil, kafka.Message{Headers: msg.Headers})
+	if consumed.TransactionID != "tx-1" || consumed.SessionID != "s-1" {
+		t.Errorf("expected ids to be read back from headers, got %s/%s", consumed.TransactionID, consumed.SessionID)
+	}
+	if consumed.Header(HeaderTransactionID) != "tx-1" {
+		t.Errorf("unexpected header %q", consumed.Header(HeaderTransactionID))
+	}
+}
+
+// flakyWriter fails its first failures writes.
+type flakyWriter struct {
+	fakeWriter
+	failures int
+	calls    int
+}
+
+func (w *flakyWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.calls++; w.calls <= w.failures {
+		return errors.New("leader not available")
+	}
+	return w.fakeWriter.WriteMessages(ctx, msgs...)
+}
+
+func TestPublishRunsPublishPolicy(t *testing.T) {
+	w := &flakyWriter{failures: 2}
+	k := newTestClient(w, nil)
+	k.config.PublishPolicy = resilience.New("kafka-publish", resilience.Config{
+		Retry: resilience.RetryConfig{Attempts: 3},
+	})
+
+	if err := k.Publish(context.Background(), "orders", []byte(`{}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.calls != 3 || len(w.messages) != 1 {
+		t.Errorf("expected the write retried until it succeeded, got %d calls and %d messages", w.calls, len(w.messages))
+	}
+
+	w.calls, w.failures = 0, 5
+	if err := k.Publish(context.Background(), "orders", []byte(`{}`)); err == nil {
+		t.Fatal("expected the error of the last attempt")
+	}
+	if w.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", w.calls)
+	}
+}
@@ -0,0 +1,105 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "gokp"
+
+// headerCarrier adapts the headers of a Kafka message to a propagation.TextMapCarrier.
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	value := ""
+	for _, h := range *c.headers {
+		if h.Key == key {
+			value = string(h.Value)
+		}
+	}
+	return value
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// startProducerSpan starts the span of publishing msg and injects its context
+// into the headers of msg.
+func startProducerSpan(ctx context.Context, msg *kafka.Message) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.operation", "publish"),
+		attribute.String("messaging.destination.name", msg.Topic),
+	}
+	if len(msg.Key) != 0 {
+		attrs = append(attrs, attribute.String("messaging.kafka.message.key", string(msg.Key)))
+	}
+	if p, ok := msg.WriterData.(explicitPartition); ok {
+		attrs = append(attrs, attribute.Int("messaging.kafka.destination.partition", int(p)))
+	}
+
+	ctx, span := otel.GetTracerProvider().Tracer(tracerName).Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	)
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &msg.Headers})
+	return ctx, span
+}
+
+// startConsumerSpan starts the span of receiving msg as a child of the span
+// that published it, when its headers carry a trace context.
+func startConsumerSpan(ctx context.Context, msg kafka.Message, consumerGroup string) (context.Context, trace.Span) {
+	headers := msg.Headers
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &headers})
+
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.operation", "receive"),
+		attribute.String("messaging.destination.name", msg.Topic),
+		attribute.Int("messaging.kafka.destination.partition", msg.Partition),
+		attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+		attribute.String("messaging.kafka.consumer.group", consumerGroup),
+	}
+	if len(msg.Key) != 0 {
+		attrs = append(attrs, attribute.String("messaging.kafka.message.key", string(msg.Key)))
+	}
+
+	return otel.GetTracerProvider().Tracer(tracerName).Start(ctx, msg.Topic+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracePropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	writer := &fakeWriter{}
+	producer := newTestClient(writer, nil)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "handler")
+	err := producer.PublishMessage(ctx, OutgoingMessage{Topic: "orders", Key: []byte("customer-1"), Value: []byte("v")})
+	parent.End()
+	if err != nil {
+		t.Fatalf("unexpected publish error: %v", err)
+	}
+	if len(writer.messages) != 1 {
+		t.Fatalf("expected 1 written message, got %d", len(writer.messages))
+	}
+
+	written := writer.messages[0]
+	written.Partition, written.Offset = 2, 10
+	consumer := newTestClient(nil, map[string]Reader{"orders": newFakeReader(written)})
+
+	msg, err := consumer.Subscribe(context.Background(), "orders")
+	if err != nil {
+		t.Fatalf("unexpected subscribe error: %v", err)
+	}
+	// the consumer span stays open for the handler of the message
+	if spans := recorder.Ended(); len(spans) != 2 {
+		t.Fatalf("expected the receive span to be open, got %d ended spans", len(spans))
+	}
+	_, work := otel.Tracer("test").Start(msg.Context(), "handle")
+	work.End()
+	msg.EndSpan(errors.New("handler failed"))
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected handler, publish, handle and receive spans, got %d", len(spans))
+	}
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+	publish, receive := byName["orders publish"], byName["orders receive"]
+	if publish == nil || receive == nil {
+		t.Fatalf("missing spans, got %v", byName)
+	}
+
+	if publish.SpanKind() != trace.SpanKindProducer || publish.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected a producer span child of the handler span")
+	}
+	if receive.SpanKind() != trace.SpanKindConsumer || receive.Parent().SpanID() != publish.SpanContext().SpanID() {
+		t.Errorf("expected the consumer span to be a child of the producer span")
+	}
+	if handle := byName["handle"]; handle == nil || handle.Parent().SpanID() != receive.SpanContext().SpanID() {
+		t.Errorf("expected the work of the handler inside the consumer span")
+	}
+	if receive.Status().Code != codes.Error {
+		t.Errorf("expected the handler error recorded on the consumer span, got %v", receive.Status())
+	}
+	if msg.RequestID != publish.SpanContext().TraceID().String() {
+		t.Errorf("expected the message to continue trace %s, got %s", publish.SpanContext().TraceID(), msg.RequestID)
+	}
+
+	want := map[attribute.Key]string{
+		"messaging.system":               "kafka",
+		"messaging.destination.name":     "orders",
+		"messaging.kafka.consumer.group": "billing",
+		"messaging.kafka.message.key":    "customer-1",
+	}
+	got := make(map[attribute.Key]attribute.Value)
+	for _, kv := range receive.Attributes() {
+		got[kv.Key] = kv.Value
+	}
+	for key, value := range want {
+		if got[key].Emit() != value {
+			t.Errorf("expected %s=%s, got %q", key, value, got[key].Emit())
+		}
+	}
+	if got["messaging.kafka.destination.partition"].AsInt64() != 2 {
+		t.Errorf("expected partition 2, got %v", got["messaging.kafka.destination.partition"].Emit())
+	}
+	if got["messaging.kafka.message.offset"].AsInt64() != 10 {
+		t.Errorf("expected offset 10, got %v", got["messaging.kafka.message.offset"].Emit())
+	}
+}
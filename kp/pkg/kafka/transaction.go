@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrPartialWrite is returned by Transaction when only some of the messages
+// could be written. The consumed offsets are not committed, so the input is
+// processed again and the written messages are published twice.
+var ErrPartialWrite = errors.New("transaction partially written")
+
+// Tx collects the messages published and the offsets committed by a Transaction.
+type Tx interface {
+	Publish(topic string, message []byte)
+	PublishMessage(msg OutgoingMessage)
+	// CommitOffset commits the offset of a consumed message once the messages
+	// of the transaction have been written.
+	CommitOffset(msg *Message)
+}
+
+// batchTx buffers a transaction in memory.
+type batchTx struct {
+	messages []OutgoingMessage
+	consumed []*Message
+}
+
+func (tx *batchTx) Publish(topic string, message []byte) {
+	tx.PublishMessage(OutgoingMessage{Topic: topic, Value: message})
+}
+
+func (tx *batchTx) PublishMessage(msg OutgoingMessage) {
+	tx.messages = append(tx.messages, msg)
+}
+
+func (tx *batchTx) CommitOffset(msg *Message) {
+	tx.consumed = append(tx.consumed, msg)
+}
+
+// Transaction runs fn, then writes the messages it published in one batch and
+// commits the offsets it marked. Nothing is written or committed when fn
+// returns an error. The batch is written through Config.PublishPolicy, and
+// the error of the commit is returned.
+//
+// The client does not support Kafka transactions: the batch is not atomic
+// across partitions and the offsets are committed only after every message
+// has been written. If the write fails, no offset is committed and the error
+// wraps ErrPartialWrite when some messages were nevertheless written. The
+// guarantee is therefore at-least-once: with Config.Idempotent, consumers can
+// deduplicate by HeaderMessageID.
+func (k *kafkaClient) Transaction(ctx context.Context, fn func(tx Tx) error) error {
+	tx := &batchTx{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.messages) != 0 {
+		if err := k.writeBatch(ctx, tx.messages); err != nil {
+			return err
+		}
+	}
+	return commitOffsets(ctx, tx.consumed)
+}
+
+// commitOffsets commits the offsets of msgs, in one CommitMessages call per
+// reader for the messages consumed by a kafkaClient.
+func commitOffsets(ctx context.Context, msgs []*Message) error {
+	var (
+		readers []Reader
+		batches = make(map[Reader][]kafka.Message)
+	)
+	for _, msg := range msgs {
+		kmsg, ok := msg.Committer.(*kafkaMessage)
+		if !ok {
+			if msg.Committer != nil {
+				msg.Commit()
+			}
+			continue
+		}
+		if kmsg.reader == nil {
+			continue
+		}
+		if _, seen := batches[kmsg.reader]; !seen {
+			readers = append(readers, kmsg.reader)
+		}
+		batches[kmsg.reader] = append(batches[kmsg.reader], *kmsg.msg)
+	}
+
+	var err error
+	for _, r := range readers {
+		err = errors.Join(err, r.CommitMessages(ctx, batches[r]...))
+	}
+	return err
+}
+
+func (k *kafkaClient) writeBatch(ctx context.Context, messages []OutgoingMessage) (err error) {
+	if k.writer == nil {
+		return errPublisherNotConfigured
+	}
+
+	topics := make([]string, len(messages))
+	for i, msg := range messages {
+		if msg.Topic == "" {
+			return errPublisherNotConfigured
+		}
+		topics[i] = msg.Topic
+	}
+	if err := k.autoCreateTopics(ctx, topics...); err != nil {
+		return err
+	}
+
+	batch := make([]kafka.Message, len(messages))
+	spans := make([]trace.Span, len(messages))
+	for i, msg := range messages {
+		batch[i] = k.kafkaMessage(msg)
+		_, spans[i] = startProducerSpan(ctx, &batch[i])
+	}
+
+	err = k.write(ctx, batch...)
+
+	var writeErrs kafka.WriteErrors
+	partial := errors.As(err, &writeErrs) && writeErrs.Count() < len(batch)
+
+	k.mu.Lock()
+	for i, msg := range batch {
+		msgErr := err
+		if partial {
+			msgErr = writeErrs[i]
+		}
+		if msgErr == nil {
+			k.published[msg.Topic]++
+		}
+		endSpan(spans[i], msgErr)
+	}
+	k.mu.Unlock()
+
+	if partial {
+		return fmt.Errorf("%w: %d of %d messages failed: %w", ErrPartialWrite, writeErrs.Count(), len(batch), err)
+	}
+	return err
+}
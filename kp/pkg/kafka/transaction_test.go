@@ -0,0 +1,194 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/resilience"
+)
+
+func consumeTestMessage(t *testing.T) (*kafkaClient, *fakeReader, *fakeWriter, *Message) {
+	t.Helper()
+
+	reader := newFakeReader(kafka.Message{Topic: "payments", Offset: 5, Value: []byte(`{"amount":10}`)})
+	writer := &fakeWriter{}
+	client := newTestClient(writer, map[string]Reader{"payments": reader})
+
+	msg, err := client.Subscribe(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("unexpected subscribe error: %v", err)
+	}
+	return client, reader, writer, msg
+}
+
+func TestTransactionCommit(t *testing.T) {
+	client, reader, writer, msg := consumeTestMessage(t)
+
+	err := client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		tx.PublishMessage(OutgoingMessage{Topic: "ledger", Key: []byte("acc-1"), Value: []byte(`{"credit":10}`)})
+		tx.CommitOffset(msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(writer.messages) != 2 || string(writer.messages[1].Key) != "acc-1" {
+		t.Fatalf("expected both messages to be written, got %+v", writer.messages)
+	}
+	if len(reader.committed) != 1 || reader.committed[0].Offset != 5 {
+		t.Errorf("expected offset 5 to be committed, got %+v", reader.committed)
+	}
+	if client.published["ledger"] != 2 {
+		t.Errorf("expected 2 published messages, got %d", client.published["ledger"])
+	}
+}
+
+func TestTransactionCommitsOffsetsAtOnce(t *testing.T) {
+	reader := newFakeReader(
+		kafka.Message{Topic: "payments", Offset: 5},
+		kafka.Message{Topic: "payments", Offset: 6},
+	)
+	client := newTestClient(&fakeWriter{}, map[string]Reader{"payments": reader})
+
+	var msgs []*Message
+	for range 2 {
+		msg, err := client.Subscribe(context.Background(), "payments")
+		if err != nil {
+			t.Fatalf("unexpected subscribe error: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	err := client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		for _, msg := range msgs {
+			tx.CommitOffset(msg)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reader.commits != 1 || len(reader.committed) != 2 {
+		t.Errorf("expected both offsets in one commit, got %d commits of %+v", reader.commits, reader.committed)
+	}
+}
+
+func TestTransactionCommitFailure(t *testing.T) {
+	client, reader, writer, msg := consumeTestMessage(t)
+	errCommit := errors.New("rebalance in progress")
+	reader.commitErr = errCommit
+
+	err := client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		tx.CommitOffset(msg)
+		return nil
+	})
+	if !errors.Is(err, errCommit) {
+		t.Fatalf("expected the commit error, got %v", err)
+	}
+	if len(writer.messages) != 1 {
+		t.Errorf("expected the message to be written before the commit, got %d", len(writer.messages))
+	}
+}
+
+func TestTransactionRunsPublishPolicy(t *testing.T) {
+	w := &flakyWriter{failures: 2}
+	client := newTestClient(w, nil)
+	client.config.PublishPolicy = resilience.New("kafka-publish", resilience.Config{
+		Retry: resilience.RetryConfig{Attempts: 3},
+	})
+
+	err := client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		tx.Publish("audit", []byte(`{"debit":10}`))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.calls != 3 || len(w.messages) != 2 {
+		t.Errorf("expected the batch retried until it succeeded, got %d calls and %d messages", w.calls, len(w.messages))
+	}
+}
+
+func TestTransactionAbort(t *testing.T) {
+	client, reader, writer, msg := consumeTestMessage(t)
+
+	errInvalid := errors.New("insufficient funds")
+	err := client.Transaction(context.Background(), func(tx Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		tx.CommitOffset(msg)
+		return errInvalid
+	})
+	if !errors.Is(err, errInvalid) {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+	if len(writer.messages) != 0 || len(reader.committed) != 0 {
+		t.Errorf("expected nothing to be written or committed, got %d and %d", len(writer.messages), len(reader.committed))
+	}
+}
+
+func TestTransactionWriteFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		partial bool
+	}{
+		{name: "failed", err: errors.New("broker down")},
+		{name: "partial", err: kafka.WriteErrors{nil, errors.New("not leader")}, partial: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, reader, writer, msg := consumeTestMessage(t)
+			writer.err = tt.err
+
+			err := client.Transaction(context.Background(), func(tx Tx) error {
+				tx.Publish("ledger", []byte(`{"debit":10}`))
+				tx.Publish("audit", []byte(`{"debit":10}`))
+				tx.CommitOffset(msg)
+				return nil
+			})
+			if err == nil {
+				t.Fatal("expected the write error")
+			}
+			if errors.Is(err, ErrPartialWrite) != tt.partial {
+				t.Errorf("unexpected partial write error: %v", err)
+			}
+			if len(reader.committed) != 0 {
+				t.Errorf("expected no offset to be committed, got %+v", reader.committed)
+			}
+		})
+	}
+}
+
+func TestIdempotentMessageID(t *testing.T) {
+	writer := &fakeWriter{}
+	client := newTestClient(writer, nil)
+	client.config.Idempotent = true
+
+	if err := client.Publish(context.Background(), "ledger", []byte("v")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := client.PublishMessage(context.Background(), OutgoingMessage{
+		Topic:   "ledger",
+		Headers: map[string]string{HeaderMessageID: "own-id"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ids := make([]string, 0, 2)
+	for _, msg := range writer.messages {
+		consumed := NewMessage(context.Background(), msg)
+		ids = append(ids, consumed.Header(HeaderMessageID))
+	}
+	if ids[0] == "" || ids[1] != "own-id" {
+		t.Errorf("expected a generated and the given message id, got %v", ids)
+	}
+}
@@ -12,8 +12,11 @@ import (
 	"time"
 
 	config "github.com/sing3demons/go-common-kp/kp/configs"
+	goHttp "github.com/sing3demons/go-common-kp/kp/pkg/http"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/metrics"
+	"github.com/sing3demons/go-common-kp/kp/pkg/resilience"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
@@ -31,6 +34,19 @@ type App struct {
 	conf        *config.Config
 
 	traceProvider *trace.TracerProvider
+	middlewaresMu sync.RWMutex
+	middlewares   []Middleware
+	metrics       *appMetrics
+	health        *health
+	httpServices  *httpServices
+	publishPolicy *resilience.Policy
+
+	stopConsumers context.CancelFunc
+	consumersDone chan struct{}
+	shutdownOnce  sync.Once
+	shutdownErr   error
+	onStart       []Hook
+	onShutdown    []Hook
 
 	maskingService logger.MaskingServiceInterface
 	AppLog         logger.LoggerService
@@ -38,69 +54,123 @@ type App struct {
 	SummaryLog     logger.LoggerService
 }
 
-func (a *App) Shutdown(ctx context.Context) error {
-	if a.httpServer != nil {
-		if err := a.httpServer.Shutdown(ctx); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func (a *App) startConsumer(ctx context.Context) error {
-	if len(a.kafkaClient.subscriptions) == 0 {
+	kc := a.kafkaClient
+	if len(kc.subscriptions) == 0 && len(kc.patterns) == 0 {
 		return nil
 	}
 
 	group := errgroup.Group{}
-	// Start subscribers concurrently using go-routines
-	for topic, handler := range a.kafkaClient.subscriptions {
-		subscriberTopic, subscriberHandler := topic, handler
+	start := func(topic string, sub *subscription) {
+		group.Go(func() error {
+			return kc.startKafkaConsumer(ctx, topic, sub)
+		})
+	}
 
+	// Start subscribers concurrently using go-routines
+	for topic, sub := range kc.subscriptions {
+		kc.consumed.add(topic)
+		start(topic, sub)
+	}
+	for _, p := range kc.patterns {
 		group.Go(func() error {
-			return a.kafkaClient.startKafkaConsumer(ctx, subscriberTopic, subscriberHandler)
+			kc.watchPattern(ctx, p, start)
+			return nil
 		})
 	}
 
 	return group.Wait()
 }
 
-func (a *App) add(method, pattern string, h Handler) {
-	hf := handler{
+func (a *App) add(method, pattern string, h Handler, mws ...Middleware) *Route {
+	return a.addRoute(a.httpServer.router, method, pattern, h, stackMiddlewares(a.appMiddlewares, mws))
+}
+
+func (a *App) addRoute(router *goHttp.Router, method, pattern string, h Handler, mws func() []Middleware) *Route {
+	hf := &handler{
 		function:       h,
-		requestTimeout: time.Duration(10) * time.Second,
+		middlewares:    mws,
+		requestTimeout: a.requestTimeout(),
 		logService: LogService{
 			appLog:         a.AppLog,
 			detailLog:      a.DetailLog,
 			summaryLog:     a.SummaryLog,
 			maskingService: a.maskingService,
 		},
-		conf: a.conf,
+		conf:         a.conf,
+		metrics:      a.metrics,
+		httpServices: a.httpServices,
 	}
 
 	if a.kafkaClient != nil {
 		hf.kafkaClient = a.kafkaClient.kafkaClient
 	}
-	a.httpServer.router.Add(method, pattern, hf)
+	router.Add(method, pattern, hf)
+
+	route := &Route{handler: hf}
+	route.Timeout(hf.requestTimeout)
+	return route
+}
+
+// requestTimeout returns the default timeout of HTTP routes from the config.
+func (a *App) requestTimeout() time.Duration {
+	if a.conf.Server.RequestTimeout != 0 {
+		return a.conf.Server.RequestTimeout
+	}
+	return defaultRequestTimeout
+}
+
+// appMiddlewares returns the app-wide middlewares. It is resolved per request
+// so Use also applies to routes registered before it.
+func (a *App) appMiddlewares() []Middleware {
+	a.middlewaresMu.RLock()
+	defer a.middlewaresMu.RUnlock()
+	return a.middlewares
 }
 
 type IApplication interface {
-	Get(pattern string, handler Handler)
-	Post(pattern string, handler Handler)
-	Put(pattern string, handler Handler)
-	Patch(pattern string, handler Handler)
-	Delete(pattern string, handler Handler)
-	Consumer(topic string, handler SubscribeFunc)
+	IRouter
+	Consumer(topic string, handler SubscribeFunc, opts ...ConsumerOption) error
+	ConsumerTopics(topics []string, handler SubscribeFunc, opts ...ConsumerOption) error
+	ConsumerPattern(pattern string, handler SubscribeFunc, opts ...ConsumerOption) error
+	BatchConsumer(topic string, handler BatchHandler, opts BatchOptions) error
 	Start()
 	CreateTopic(topic string)
+	Admin() kafka.Admin
 
 	StartKafka()
+	Metrics() *metrics.Registry
+	AddHealthChecker(checkers ...HealthChecker)
+	AddHTTPService(services ...HTTPService)
+	OnStart(hooks ...Hook)
+	OnShutdown(hooks ...Hook)
 
 	LogDetail(logger logger.LoggerService)
 	LogSummary(logger logger.LoggerService)
 }
 
-func NewApplication(conf *config.Config) IApplication {
+// AppOption configures an App created by NewApplication.
+type AppOption func(*App)
+
+// WithKafkaClient uses client as the Kafka client of the app instead of
+// connecting to the brokers of the config in StartKafka, e.g. the client of a
+// kafka.MemoryBroker to run a service under go test.
+func WithKafkaClient(client kafka.Client) AppOption {
+	return func(a *App) {
+		a.useKafkaClient(client)
+	}
+}
+
+// WithPublishPolicy runs the writes of the Kafka client created by StartKafka
+// under a resilience policy named "kafka-publish", logged to the app log and
+// exported on the metrics endpoint.
+func WithPublishPolicy(conf resilience.Config) AppOption {
+	return func(a *App) {
+		a.publishPolicy = resilience.New("kafka-publish", conf, a.resilienceOptions()...)
+	}
+}
+
+func NewApplication(conf *config.Config, opts ...AppOption) IApplication {
 	// noopLogger := logger.NewDefaultLoggerService()
 
 	logApp := logger.NewLogger(conf.Log.App)
@@ -124,41 +194,140 @@ func NewApplication(conf *config.Config) IApplication {
 
 	app := &App{
 		conf:           conf,
+		traceProvider:  traceProvider,
 		AppLog:         logApp,
 		DetailLog:      logDetail,
 		SummaryLog:     logSummary,
 		maskingService: logger.NewMaskingService(),
+		metrics:        newAppMetrics(),
+		health:         newHealth(),
+		httpServices:   newHTTPServices(),
 	}
 
 	app.httpServer = newHTTPServer(conf, traceProvider)
-	// app.kafkaClient = kafka.New(&kafka.Config{})
+	app.registerMetricsEndpoint()
+	app.registerHealthEndpoints()
+
+	for _, opt := range opts {
+		opt(app)
+	}
 
 	return app
 }
 
 func (a *App) StartKafka() {
+	if a.kafkaClient != nil {
+		a.AppLog.Debug("Kafka client already configured.")
+		return
+	}
 	a.AppLog.Debug("Starting Kafka client...")
 
 	if a.conf.Kafka.Broker == "" {
 		panic("Kafka broker is not configured.")
 	}
 
+	conf := a.conf.Kafka
 	kafkaClient := kafka.New(&kafka.Config{
-		Brokers:         strings.Split(a.conf.Kafka.Broker, ","),
-		BatchSize:       a.conf.Kafka.BatchSize,
-		BatchBytes:      a.conf.Kafka.BatchBytes,
-		BatchTimeout:    a.conf.Kafka.BatchTimeout,
-		ConsumerGroupID: a.conf.Kafka.ConsumerGroupID,
+		Brokers:           strings.Split(conf.Broker, ","),
+		Partition:         conf.Partition,
+		ConsumerGroupID:   conf.ConsumerGroupID,
+		OffSet:            conf.OffSet,
+		BatchSize:         conf.BatchSize,
+		BatchBytes:        conf.BatchBytes,
+		BatchTimeout:      conf.BatchTimeout,
+		RetryTimeout:      conf.RetryTimeout,
+		SASLMechanism:     conf.SASLMechanism,
+		SASLUser:          conf.SASLUser,
+		SASLPassword:      conf.SASLPassword,
+		SecurityProtocol:  conf.SecurityProtocol,
+		MinBytes:          conf.MinBytes,
+		MaxBytes:          conf.MaxBytes,
+		SessionTimeout:    conf.SessionTimeout,
+		HeartbeatInterval: conf.HeartbeatInterval,
+		RebalanceStrategy: conf.RebalanceStrategy,
+		CommitInterval:    conf.CommitInterval,
+		IsolationLevel:    conf.IsolationLevel,
+		RequiredAcks:      conf.RequiredAcks,
+		Compression:       conf.Compression,
+		Balancer:          conf.Balancer,
+		AutoCreateTopic:   conf.AutoCreateTopic,
+		Idempotent:        conf.Idempotent,
+		PublishPolicy:     a.publishPolicy,
+		TLS: kafka.TLSConfig{
+			CertFile:           conf.TLS.CertFile,
+			KeyFile:            conf.TLS.KeyFile,
+			CACertFile:         conf.TLS.CACertFile,
+			InsecureSkipVerify: conf.TLS.InsecureSkipVerify,
+		},
 	})
+	if kafkaClient == nil {
+		panic("Kafka configuration is invalid.")
+	}
 
+	a.useKafkaClient(kafkaClient)
+	a.AppLog.Debug(fmt.Sprintf("Kafka client initialized with broker: %s", a.conf.Kafka.Broker))
+}
+
+// useKafkaClient makes kafkaClient the client of consumers, handlers, health
+// checks and metrics.
+func (a *App) useKafkaClient(kafkaClient kafka.Client) {
 	a.kafkaClient = newKafkaClient(kafkaClient, LogService{
 		maskingService: a.maskingService,
 		appLog:         a.AppLog,
 		detailLog:      a.DetailLog,
 		summaryLog:     a.SummaryLog,
 	}, a.conf)
-	a.AppLog.Debug(fmt.Sprintf("Kafka client initialized with broker: %s", a.conf.Kafka.Broker))
+	a.kafkaClient.metrics = a.metrics
+	a.kafkaClient.httpServices = a.httpServices
+	a.health.add(kafkaHealthChecker{client: kafkaClient})
+	a.metrics.registry.OnCollect(func() {
+		a.metrics.collectKafka(kafkaClient)
+	})
+}
+
+// Metrics returns the registry exposed on the metrics endpoint.
+func (a *App) Metrics() *metrics.Registry {
+	return a.metrics.registry
+}
+
+// registerMetricsEndpoint serves the registry on Server.MetricsPath. The
+// endpoint bypasses the kp handler so scrapes do not produce detail/summary logs.
+func (a *App) registerMetricsEndpoint() {
+	path := a.conf.Server.MetricsPath
+	if path == "" {
+		path = defaultMetricsPath
+	}
+
+	a.httpServer.router.Handle(path, a.metrics.registry.Handler()).Methods(http.MethodGet)
+}
+
+// AddHealthChecker adds dependencies checked by the readiness endpoint.
+func (a *App) AddHealthChecker(checkers ...HealthChecker) {
+	a.health.add(checkers...)
+}
+
+// AddHTTPService registers downstream services for Context.HTTPClient. A
+// service replaces the one registered with the same name.
+func (a *App) AddHTTPService(services ...HTTPService) {
+	for _, svc := range services {
+		a.httpServices.add(svc, a.resilienceOptions()...)
+	}
+}
+
+// resilienceOptions make policies log to the app log and export their
+// metrics on the metrics endpoint.
+func (a *App) resilienceOptions() []resilience.Option {
+	return []resilience.Option{
+		resilience.WithLogger(a.AppLog),
+		resilience.WithMetrics(a.metrics.registry),
+	}
+}
 
+// registerHealthEndpoints serves liveness and readiness outside the kp handler,
+// so probes do not produce detail/summary logs.
+func (a *App) registerHealthEndpoints() {
+	a.httpServer.router.HandleFunc(healthLivePath, a.health.liveHandler).Methods(http.MethodGet)
+	a.httpServer.router.HandleFunc(healthReadyPath, a.health.readyHandler).Methods(http.MethodGet)
 }
 
 func (a *App) LogDetail(logger logger.LoggerService) {
@@ -169,35 +338,117 @@ func (a *App) LogSummary(logger logger.LoggerService) {
 	a.SummaryLog = logger
 }
 
-func (a *App) Get(pattern string, handler Handler) {
-	a.add(http.MethodGet, pattern, handler)
+// Use registers app-wide middlewares that run, in order, before the
+// middlewares of every HTTP route. Use must be called before Start: requests
+// served in the meantime may run without the new middlewares.
+func (a *App) Use(mws ...Middleware) {
+	a.middlewaresMu.Lock()
+	defer a.middlewaresMu.Unlock()
+	a.middlewares = append(a.middlewares[:len(a.middlewares):len(a.middlewares)], mws...)
 }
-func (a *App) Post(pattern string, handler Handler) {
-	a.add(http.MethodPost, pattern, handler)
+
+// Group returns a router for routes sharing the given path prefix and middlewares.
+func (a *App) Group(prefix string, mws ...Middleware) IRouter {
+	return newRouteGroup(a, a.httpServer.router.Group(prefix), a.appMiddlewares, mws)
+}
+
+func (a *App) Get(pattern string, handler Handler, mws ...Middleware) *Route {
+	return a.add(http.MethodGet, pattern, handler, mws...)
+}
+func (a *App) Post(pattern string, handler Handler, mws ...Middleware) *Route {
+	return a.add(http.MethodPost, pattern, handler, mws...)
 }
-func (a *App) Put(pattern string, handler Handler) {
-	a.add(http.MethodPut, pattern, handler)
+func (a *App) Put(pattern string, handler Handler, mws ...Middleware) *Route {
+	return a.add(http.MethodPut, pattern, handler, mws...)
 }
-func (a *App) Patch(pattern string, handler Handler) {
-	a.add(http.MethodPatch, pattern, handler)
+func (a *App) Patch(pattern string, handler Handler, mws ...Middleware) *Route {
+	return a.add(http.MethodPatch, pattern, handler, mws...)
 }
-func (a *App) Delete(pattern string, handler Handler) {
-	a.add(http.MethodDelete, pattern, handler)
+func (a *App) Delete(pattern string, handler Handler, mws ...Middleware) *Route {
+	return a.add(http.MethodDelete, pattern, handler, mws...)
 }
 
-func (a *App) Consumer(topic string, handler SubscribeFunc) {
+// Consumer subscribes handler to topic. By default messages are handled one at
+// a time; see WithConcurrency to handle partitions in parallel. It returns an
+// error wrapping ErrSubscriptionExists if topic, or one of its retry topics, is
+// already subscribed.
+func (a *App) Consumer(topic string, handler SubscribeFunc, opts ...ConsumerOption) error {
+	return a.ConsumerTopics([]string{topic}, handler, opts...)
+}
+
+// ConsumerTopics subscribes handler to each of topics, with the same options.
+// Nothing is subscribed if one of the topics is already subscribed.
+func (a *App) ConsumerTopics(topics []string, handler SubscribeFunc, opts ...ConsumerOption) error {
 	if a.kafkaClient == nil {
-		a.AppLog.Debug("Kafka client is not initialized.")
-		return
+		return a.consumerError(errKafkaNotConfigured)
 	}
 
-	if _, exists := a.kafkaClient.subscriptions[topic]; exists {
-		a.AppLog.Debug(fmt.Sprintf("Subscription for topic %s already exists.", topic))
-		return
+	subs := make([]*subscription, len(topics))
+	for i, topic := range topics {
+		subs[i] = newSubscription(topic, handler, opts...)
+	}
+	if err := a.kafkaClient.subscribe(subs...); err != nil {
+		return a.consumerError(err)
+	}
+
+	for _, sub := range subs {
+		a.AppLog.Debug(fmt.Sprintf("Subscribed to topic %s successfully.", sub.topic))
+		for _, retry := range sub.retrySubscriptions() {
+			a.AppLog.Debug(fmt.Sprintf("Subscribed to retry topic %s successfully.", retry.topicName()))
+		}
+	}
+	return nil
+}
+
+// ConsumerPattern subscribes handler to every topic whose name matches the
+// regular expression pattern, e.g. `^orders\..*`. Topics are discovered when
+// the app starts and then periodically, see WithDiscoveryInterval. Retry and
+// dead-letter topics never match, and a topic subscribed with Consumer, or
+// matched by an earlier pattern, keeps its own handler.
+func (a *App) ConsumerPattern(pattern string, handler SubscribeFunc, opts ...ConsumerOption) error {
+	if a.kafkaClient == nil {
+		return a.consumerError(errKafkaNotConfigured)
+	}
+
+	if err := a.kafkaClient.subscribePattern(pattern, newSubscription("", handler, opts...)); err != nil {
+		return a.consumerError(err)
+	}
+	a.AppLog.Debug(fmt.Sprintf("Subscribed to topic pattern %s successfully.", pattern))
+	return nil
+}
+
+// BatchConsumer subscribes handler to topic. Messages are delivered in batches
+// and the batch is committed when handler succeeds, or once opts.Retry has
+// forwarded its messages to a retry or dead-letter topic.
+func (a *App) BatchConsumer(topic string, handler BatchHandler, opts BatchOptions) error {
+	if a.kafkaClient == nil {
+		return a.consumerError(errKafkaNotConfigured)
+	}
+
+	sub := &subscription{topic: topic, batch: handler, batchOptions: opts, retry: opts.Retry}
+	if err := a.kafkaClient.subscribe(sub); err != nil {
+		return a.consumerError(err)
 	}
+	a.AppLog.Debug(fmt.Sprintf("Subscribed to topic %s in batches successfully.", topic))
+	for _, retry := range sub.retrySubscriptions() {
+		a.AppLog.Debug(fmt.Sprintf("Subscribed to retry topic %s successfully.", retry.topicName()))
+	}
+	return nil
+}
 
-	a.kafkaClient.subscriptions[topic] = handler
-	a.AppLog.Debug(fmt.Sprintf("Subscribed to topic %s successfully.", topic))
+// consumerError logs a failed consumer registration and returns err.
+func (a *App) consumerError(err error) error {
+	a.AppLog.Errorf("Consumer registration failed: %v", err)
+	return err
+}
+
+// Admin returns the topic administration API of the Kafka client, or nil if
+// StartKafka was not called.
+func (a *App) Admin() kafka.Admin {
+	if a.kafkaClient == nil {
+		return nil
+	}
+	return a.kafkaClient.kafkaClient
 }
 
 func (a *App) CreateTopic(topic string) {
@@ -254,13 +505,26 @@ func (a *App) Start() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	timeout, _ := getShutdownTimeoutFromConfig()
+	timeout := getShutdownTimeoutFromConfig(a.conf)
+
+	if err := a.runStartHooks(ctx); err != nil {
+		a.AppLog.Errorf("Application startup aborted: %v", err)
+
+		// release what the hooks that succeeded have opened: every shutdown
+		// hook runs, see OnShutdown
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := a.Shutdown(shutdownCtx); err != nil {
+			a.AppLog.Errorf("Server shutdown failed: %v", err)
+		}
+		return
+	}
 
 	wg := sync.WaitGroup{}
 
 	if a.httpServer != nil {
 		wg.Add(1)
-		a.AppLog.Debugf("Starting HTTP server on port %d", a.httpServer.port)
+		a.AppLog.Debugf("Starting HTTP server on port %s", a.httpServer.port)
 		go func(s *httpServer) {
 			defer wg.Done()
 			s.run()
@@ -268,31 +532,32 @@ func (a *App) Start() {
 	}
 
 	if a.kafkaClient != nil {
-		wg.Add(1)
-		a.AppLog.Debugf("Starting Kafka consumer with subscriptions: %v", a.kafkaClient.subscriptions)
-		go func() {
-			defer wg.Done()
-			if err := a.startConsumer(ctx); err != nil {
-				a.AppLog.Errorf("Error starting Kafka consumer: %v", err)
+		// Consumers get their own context so shutdown can stop fetching
+		// before the HTTP server and the Kafka client are closed.
+		consumerCtx, cancel := context.WithCancel(context.Background())
+		a.stopConsumers = cancel
+		a.consumersDone = make(chan struct{})
+
+		if a.conf.Kafka.AutoCreateTopic {
+			if err := a.kafkaClient.ensureTopics(context.Background()); err != nil {
+				a.AppLog.Errorf("Error creating Kafka topics: %v", err)
 			}
-		}()
-	}
+		}
 
-	if a.traceProvider != nil {
 		wg.Add(1)
-		a.AppLog.Debug("Starting tracer provider shutdown")
+		a.AppLog.Debugf("Starting Kafka consumer with subscriptions: %v", a.kafkaClient.topics())
 		go func() {
 			defer wg.Done()
-			// Wait for the tracer provider to be shutdown
-			if err := a.traceProvider.Shutdown(ctx); err != nil {
-				a.AppLog.Errorf("Error shutting down tracer provider: %v", err)
-			} else {
-				a.AppLog.Debug("Tracer provider shutdown completed successfully.")
+			defer close(a.consumersDone)
+			if err := a.startConsumer(consumerCtx); err != nil {
+				a.AppLog.Errorf("Error starting Kafka consumer: %v", err)
 			}
 		}()
 	}
 
+	shutdownDone := make(chan struct{})
 	go func() {
+		defer close(shutdownDone)
 		<-ctx.Done()
 
 		// Give services time to shutdown
@@ -306,9 +571,10 @@ func (a *App) Start() {
 		}
 	}()
 
-	defer a.AppLog.Sync()
-	defer a.DetailLog.Sync()
-	defer a.SummaryLog.Sync()
-
 	wg.Wait()
+
+	// Servers may also stop on their own (e.g. the port is in use):
+	// run the shutdown sequence anyway and wait for it to complete.
+	stop()
+	<-shutdownDone
 }
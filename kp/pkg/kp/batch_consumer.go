@@ -0,0 +1,276 @@
+package kp
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+const (
+	defaultBatchMaxMessages = 100
+	defaultBatchMaxWait     = time.Second
+)
+
+// defaultBatchRetry is the backoff between runs of a failed batch when the
+// consumer has no retry policy, or one without a Backoff.
+var defaultBatchRetry = RetryPolicy{Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// BatchOptions configures a batch consumer. A batch is handled once it holds
+// MaxMessages messages or MaxWait has passed since its first message.
+type BatchOptions struct {
+	MaxMessages int           // defaults to 100
+	MaxWait     time.Duration // defaults to 1s
+
+	// Retry forwards the messages of a batch that failed Retry.Attempts times
+	// to the retry or dead-letter topics of the consumer, which are consumed
+	// in batches too. Without it, a failed batch is handled again, with
+	// backoff, until it succeeds. In both cases no further batch is fetched
+	// before the failed one is committed.
+	Retry *RetryPolicy
+}
+
+// BatchHandler handles the messages of a batch consumer.
+type BatchHandler func(c *BatchContext) error
+
+// BatchContext is the context of a batch of messages. The embedded Context
+// writes one summary log for the whole batch, with a sequence result per
+// message.
+type BatchContext struct {
+	*Context
+	Messages []*kafka.Message
+}
+
+func (o BatchOptions) withDefaults() BatchOptions {
+	if o.MaxMessages <= 0 {
+		o.MaxMessages = defaultBatchMaxMessages
+	}
+	if o.MaxWait <= 0 {
+		o.MaxWait = defaultBatchMaxWait
+	}
+	return o
+}
+
+// startBatchConsumer collects batches of topic and hands them to the batch
+// handler of sub. A batch being collected or retried when ctx is done is not
+// committed: its messages will be delivered again.
+func (kc *KafkaClient) startBatchConsumer(ctx context.Context, topic string, sub *subscription) error {
+	opts := sub.batchOptions.withDefaults()
+
+	for {
+		batch := kc.collectBatch(ctx, topic, opts)
+		if len(batch) != 0 {
+			kc.handleBatch(ctx, topic, batch, sub)
+		}
+		if ctx.Err() != nil {
+			kc.log.appLog.Logf("shutting down subscriber for topic %s", topic)
+			return nil
+		}
+	}
+}
+
+// collectBatch waits for a first message, then adds messages until the batch
+// is full or opts.MaxWait has passed.
+func (kc *KafkaClient) collectBatch(ctx context.Context, topic string, opts BatchOptions) []*kafka.Message {
+	var batch []*kafka.Message
+	fetchCtx := ctx
+
+	for len(batch) < opts.MaxMessages {
+		msg, err := kc.kafkaClient.Subscribe(fetchCtx, topic)
+		if ctx.Err() != nil || fetchCtx.Err() != nil {
+			if err == nil && msg != nil {
+				// fetched as the batch closed: it will be delivered again
+				msg.EndSpan(nil)
+			}
+			break
+		}
+		if err != nil {
+			kc.log.appLog.Errorf("error in subscription for topic %s: %v", topic, err)
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+
+		batch = append(batch, msg)
+		if len(batch) == 1 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(ctx, opts.MaxWait)
+			defer cancel()
+		}
+	}
+	return batch
+}
+
+// handleBatch runs the handler of sub for the batch until it succeeds, or
+// until the retry policy of sub forwards the messages of the batch to a retry
+// or dead-letter topic, then commits the last message of every partition of
+// the batch. Later batches would commit higher offsets, so it returns without
+// a commit only once ctx is done. The consumer spans of the messages cover
+// the handler runs, the forwards and the commit.
+func (kc *KafkaClient) handleBatch(ctx context.Context, topic string, batch []*kafka.Message, sub *subscription) {
+	var err error
+	defer func() {
+		for _, msg := range batch {
+			msg.EndSpan(err)
+		}
+	}()
+
+	if ctx.Err() != nil {
+		return
+	}
+	for _, msg := range batch {
+		if !waitRetryDelay(ctx, msg) {
+			return
+		}
+	}
+
+	err = kc.runBatchHandler(topic, batch, sub.batch)
+	attempts := 1
+	for ; err != nil && (sub.retry == nil || attempts < sub.retry.attempts()); attempts++ {
+		if !sleep(ctx, sub.batchBackoff(attempts)) {
+			return
+		}
+		err = kc.runBatchHandler(topic, batch, sub.batch)
+	}
+
+	if err != nil {
+		for _, msg := range batch {
+			for failed := 1; ; failed++ {
+				ferr := kc.forwardFailed(ctx, msg, sub, attempts, err)
+				if ferr == nil {
+					break
+				}
+				kc.log.appLog.Errorf("error forwarding failed message of topic %s: %v", topic, ferr)
+				if !sleep(ctx, sub.batchBackoff(failed)) {
+					return
+				}
+			}
+		}
+	}
+
+	last := make(map[int]*kafka.Message)
+	for _, msg := range batch {
+		if prev, ok := last[msg.Partition]; !ok || msg.Offset > prev.Offset {
+			last[msg.Partition] = msg
+		}
+	}
+	for _, msg := range last {
+		if msg.Committer != nil {
+			msg.Commit()
+		}
+	}
+}
+
+// runBatchHandler runs handler for the batch, with one summary log.
+func (kc *KafkaClient) runBatchHandler(topic string, batch []*kafka.Message, handler BatchHandler) error {
+	msgCtx := newContext(nil, newBatchRequest(topic, batch), kc.kafkaClient, kc.log, kc.conf)
+	msgCtx.metrics = kc.metrics
+	msgCtx.httpServices = kc.httpServices
+	msgCtx.Context = context.WithoutCancel(msgCtx.Context)
+
+	err := func(ctx *Context) (err error) {
+		defer func() {
+			if re := recover(); re != nil {
+				err = recoverPanic(ctx, re, "kafka")
+			}
+		}()
+
+		return handler(&BatchContext{Context: ctx, Messages: batch})
+	}(msgCtx)
+	msgCtx.closeSummary(err)
+	for range batch {
+		kc.metrics.observeKafka(topic, err)
+	}
+
+	if err != nil {
+		kc.log.appLog.Errorf("error in batch handler for topic %s: %v", topic, err)
+	}
+	return err
+}
+
+// batchBackoff returns the wait after the given number of failed runs of a
+// batch, or of failed forwards of one of its messages.
+func (s *subscription) batchBackoff(failed int) time.Duration {
+	if s.retry != nil && s.retry.Backoff > 0 {
+		return s.retry.backoff(failed)
+	}
+	return defaultBatchRetry.backoff(failed)
+}
+
+// batchRequest is the Request of a batch: it takes its context and IDs from
+// the first message and its body is the JSON array of the message values.
+type batchRequest struct {
+	*kafka.Message
+	topic    string
+	messages []*kafka.Message
+}
+
+func newBatchRequest(topic string, messages []*kafka.Message) *batchRequest {
+	return &batchRequest{Message: messages[0], topic: topic, messages: messages}
+}
+
+// requests returns the messages of the batch as requests.
+func (r *batchRequest) requests() []Request {
+	requests := make([]Request, len(r.messages))
+	for i, msg := range r.messages {
+		requests[i] = msg
+	}
+	return requests
+}
+
+func (r *batchRequest) Param(p string) string {
+	switch p {
+	case "topic":
+		return r.topic
+	case "consumer_group":
+		return r.Message.Param(p)
+	}
+	return ""
+}
+
+func (r *batchRequest) PathParam(p string) string {
+	return r.Param(p)
+}
+
+func (r *batchRequest) URL() string {
+	return r.topic
+}
+
+func (r *batchRequest) Body() (string, error) {
+	values := make([]string, len(r.messages))
+	for i, msg := range r.messages {
+		values[i] = string(msg.Value)
+	}
+	body := "[" + strings.Join(values, ",") + "]"
+	if !json.Valid([]byte(body)) {
+		// not JSON values: send them as strings
+		b, _ := json.Marshal(values)
+		return string(b), nil
+	}
+	return body, nil
+}
+
+// Bind binds the values of the batch to i, a pointer to a slice.
+func (r *batchRequest) Bind(i any) error {
+	body, err := r.Body()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(body), i)
+}
+
+func (r *batchRequest) Header(string) string {
+	return ""
+}
+
+func (r *batchRequest) Headers() map[string]string {
+	return nil
+}
+
+func (r *batchRequest) Query() url.Values {
+	return nil
+}
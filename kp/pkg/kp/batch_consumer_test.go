@@ -0,0 +1,178 @@
+package kp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+)
+
+func TestBatchConsumer(t *testing.T) {
+	client := &queueKafkaClient{queue: make(chan *kafka.Message, 8)}
+	kc, log := newTestKafkaClient(t, &MockKafkaClient{})
+	kc.kafkaClient = client
+
+	mu := sync.Mutex{}
+	var commits []string
+	offsets := map[int]int64{}
+	for i := 0; i < 5; i++ {
+		partition := i % 2
+		id := fmt.Sprintf("%d/%d", partition, offsets[partition])
+		client.queue <- &kafka.Message{
+			Topic:     "payments",
+			Partition: partition,
+			Offset:    offsets[partition],
+			Value:     []byte(fmt.Sprintf(`{"id":%d}`, i)),
+			Committer: recordingCommitter{mu: &mu, commits: &commits, id: id},
+		}
+		offsets[partition]++
+	}
+
+	var batches [][]int
+	done := make(chan struct{})
+	handler := func(c *BatchContext) error {
+		var values []struct{ ID int }
+		if err := c.Bind(&values); err != nil {
+			t.Errorf("unexpected bind error: %v", err)
+		}
+		ids := make([]int, len(values))
+		for i, v := range values {
+			ids[i] = v.ID
+		}
+		batches = append(batches, ids)
+		if len(batches) == 2 {
+			close(done)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	sub := &subscription{topic: "payments", batch: handler, batchOptions: BatchOptions{MaxMessages: 3, MaxWait: 20 * time.Millisecond}}
+	go func() { stopped <- kc.startKafkaConsumer(ctx, "payments", sub) }()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("batches were not handled in time")
+	}
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Fatalf("unexpected consumer error: %v", err)
+	}
+
+	if fmt.Sprint(batches) != "[[0 1 2] [3 4]]" {
+		t.Errorf("unexpected batches %v", batches)
+	}
+
+	// the last message of every partition of each batch is committed
+	mu.Lock()
+	committed := map[string]bool{}
+	for _, id := range commits {
+		committed[id] = true
+	}
+	mu.Unlock()
+	for _, id := range []string{"0/1", "1/0", "0/2", "1/1"} {
+		if !committed[id] {
+			t.Errorf("expected %s to be committed, got %v", id, commits)
+		}
+	}
+	if len(commits) != 4 {
+		t.Errorf("expected 4 commits, got %v", commits)
+	}
+
+	summaryLog := log.summaryLog.(*MockLoggerService)
+	if len(summaryLog.InfoCalls) != 2 {
+		t.Fatalf("expected 1 summary log per batch, got %d", len(summaryLog.InfoCalls))
+	}
+	var summary struct {
+		Flow []logger.EventSummary `json:"flow"`
+	}
+	if err := json.Unmarshal([]byte(summaryLog.InfoCalls[0]), &summary); err != nil {
+		t.Fatalf("invalid summary log: %v", err)
+	}
+	if len(summary.Flow) != 1 || len(summary.Flow[0].Result) != 3 {
+		t.Errorf("expected a sequence result per message, got %+v", summary.Flow)
+	}
+}
+
+func TestBatchConsumerFailureKeepsOffsets(t *testing.T) {
+	kc, _ := newTestKafkaClient(t, &MockKafkaClient{})
+
+	commits := 0
+	batch := []*kafka.Message{
+		{Topic: "payments", Value: []byte(`{"id":1}`), Committer: countingCommitter{commits: &commits}},
+		{Topic: "payments", Offset: 1, Value: []byte(`{"id":2}`), Committer: countingCommitter{commits: &commits}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	sub := &subscription{topic: "payments", batch: func(c *BatchContext) error {
+		runs++
+		cancel()
+		return ErrInternal(errors.New("bulk insert failed"))
+	}}
+	kc.handleBatch(ctx, "payments", batch, sub)
+
+	if runs != 1 || commits != 0 {
+		t.Errorf("expected no commit when stopped during the backoff, got %d runs and %d commits", runs, commits)
+	}
+}
+
+func TestBatchConsumerRetriesFailedBatch(t *testing.T) {
+	kc, _ := newTestKafkaClient(t, &MockKafkaClient{})
+
+	commits := 0
+	batch := []*kafka.Message{
+		{Topic: "payments", Value: []byte(`{"id":1}`), Committer: countingCommitter{commits: &commits}},
+	}
+	defer func(backoff RetryPolicy) { defaultBatchRetry = backoff }(defaultBatchRetry)
+	defaultBatchRetry = RetryPolicy{Backoff: time.Millisecond}
+
+	runs := 0
+	sub := &subscription{topic: "payments", batch: func(c *BatchContext) error {
+		if runs++; runs < 3 {
+			return ErrInternal(errors.New("bulk insert failed"))
+		}
+		return nil
+	}}
+	kc.handleBatch(context.Background(), "payments", batch, sub)
+
+	if runs != 3 || commits != 1 {
+		t.Errorf("expected the batch run until it succeeds, then committed: got %d runs and %d commits", runs, commits)
+	}
+}
+
+func TestBatchConsumerForwardsFailedBatch(t *testing.T) {
+	client := &MockKafkaClient{}
+	kc, _ := newTestKafkaClient(t, client)
+
+	commits := 0
+	batch := []*kafka.Message{
+		newRetryTestMessage("payments", &commits),
+		newRetryTestMessage("payments", &commits),
+	}
+	batch[1].Offset = 1
+	runs := 0
+	sub := &subscription{
+		topic: "payments",
+		retry: &RetryPolicy{Attempts: 2, Backoff: time.Millisecond, DeadLetter: true},
+		batch: func(c *BatchContext) error {
+			runs++
+			return ErrInternal(errors.New("bulk insert failed"))
+		},
+	}
+	kc.handleBatch(context.Background(), "payments", batch, sub)
+
+	if runs != 2 || len(client.ForwardCalls) != 2 || commits != 1 {
+		t.Fatalf("expected 2 runs, 2 forwards and 1 commit, got %d, %d and %d", runs, len(client.ForwardCalls), commits)
+	}
+	if call := client.ForwardCalls[0]; call.Topic != "payments.dlq" || call.Headers[HeaderRetryAttempt] != "2" {
+		t.Errorf("unexpected forward to %s with %v", call.Topic, call.Headers)
+	}
+}
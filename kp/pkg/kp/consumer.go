@@ -0,0 +1,117 @@
+package kp
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+// workerQueueSize is the number of fetched messages that may wait for a busy worker.
+const workerQueueSize = 8
+
+// ConsumerOption configures a subscription registered with Consumer.
+type ConsumerOption func(*subscription)
+
+// WithConcurrency handles up to n messages of the topic in parallel.
+//
+// Messages are dispatched to workers by partition, so messages of one
+// partition (and therefore of one key) are still handled in order, and an
+// offset is committed only after every earlier message of its partition has
+// been handled. Values below 2 keep the default of one message at a time.
+func WithConcurrency(n int) ConsumerOption {
+	return func(s *subscription) {
+		s.concurrency = n
+	}
+}
+
+type subscription struct {
+	handler     SubscribeFunc
+	concurrency int
+	retry       *RetryPolicy
+
+	// discoveryInterval is how often a pattern subscription lists topics.
+	discoveryInterval time.Duration
+
+	// batch, when set, replaces handler for batch consumers.
+	batch        BatchHandler
+	batchOptions BatchOptions
+
+	// topic is the topic passed to Consumer and stage the index of the retry
+	// topic consumed, starting at 1; 0 is the topic itself.
+	topic string
+	stage int
+}
+
+func newSubscription(topic string, handler SubscribeFunc, opts ...ConsumerOption) *subscription {
+	s := &subscription{handler: handler, concurrency: 1, topic: topic}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// topics returns the subscribed topics in a stable order.
+func (kc *KafkaClient) topics() []string {
+	topics := make([]string, 0, len(kc.subscriptions))
+	for topic := range kc.subscriptions {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// startWorkerPool fetches messages of topic and hands each one to the worker
+// owning its partition. Once ctx is done it waits for the workers to stop: the
+// messages being handled are finished and committed, while those still queued
+// are dropped uncommitted, to be delivered again.
+func (kc *KafkaClient) startWorkerPool(ctx context.Context, topic string, sub *subscription) error {
+	workers := make([]chan *kafka.Message, sub.concurrency)
+	wg := sync.WaitGroup{}
+
+	for i := range workers {
+		workers[i] = make(chan *kafka.Message, workerQueueSize)
+
+		wg.Add(1)
+		go func(queue <-chan *kafka.Message) {
+			defer wg.Done()
+			for msg := range queue {
+				kc.handleMessage(ctx, topic, msg, sub)
+			}
+		}(workers[i])
+	}
+
+	defer func() {
+		for _, queue := range workers {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		msg, err := kc.kafkaClient.Subscribe(ctx, topic)
+		if ctx.Err() != nil {
+			kc.log.appLog.Logf("shutting down subscriber for topic %s", topic)
+			return nil
+		}
+		if err != nil {
+			kc.log.appLog.Errorf("error in subscription for topic %s: %v", topic, err)
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+
+		queue := workers[msg.Partition%len(workers)]
+		select {
+		case queue <- msg:
+		case <-ctx.Done():
+			// the fetched message is not committed and will be delivered again
+			msg.EndSpan(nil)
+			kc.log.appLog.Logf("shutting down subscriber for topic %s", topic)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package kp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+// queueKafkaClient delivers queued messages to Subscribe and then blocks until
+// the consumer is stopped.
+type queueKafkaClient struct {
+	MockKafkaClient
+	queue chan *kafka.Message
+}
+
+func (q *queueKafkaClient) Subscribe(ctx context.Context, topic string) (*kafka.Message, error) {
+	select {
+	case msg := <-q.queue:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type recordingCommitter struct {
+	mu      *sync.Mutex
+	commits *[]string
+	id      string
+}
+
+func (r recordingCommitter) Commit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.commits = append(*r.commits, r.id)
+}
+
+func TestConsumerConcurrencyKeepsPartitionOrder(t *testing.T) {
+	const partitions, perPartition = 3, 5
+
+	client := &queueKafkaClient{queue: make(chan *kafka.Message, partitions*perPartition)}
+	kc, _ := newTestKafkaClient(t, &MockKafkaClient{})
+	kc.kafkaClient = client
+
+	mu := sync.Mutex{}
+	var commits []string
+	for offset := 0; offset < perPartition; offset++ {
+		for p := 0; p < partitions; p++ {
+			id := fmt.Sprintf("%d/%d", p, offset)
+			client.queue <- &kafka.Message{
+				Topic:     "orders",
+				Partition: p,
+				Offset:    int64(offset),
+				Value:     []byte(id),
+				Committer: recordingCommitter{mu: &mu, commits: &commits, id: id},
+			}
+		}
+	}
+
+	handled := make(map[int][]int64)
+	running, maxRunning := 0, 0
+	done := make(chan struct{})
+	total := 0
+	handler := func(c *Context) error {
+		msg := c.Request.(*kafka.Message)
+
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		handled[msg.Partition] = append(handled[msg.Partition], msg.Offset)
+		total++
+		if total == partitions*perPartition {
+			close(done)
+		}
+		mu.Unlock()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- kc.startKafkaConsumer(ctx, "orders", newSubscription("orders", handler, WithConcurrency(partitions)))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("messages were not handled in time")
+	}
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Fatalf("unexpected consumer error: %v", err)
+	}
+
+	for p := 0; p < partitions; p++ {
+		for i, offset := range handled[p] {
+			if offset != int64(i) {
+				t.Fatalf("partition %d handled out of order: %v", p, handled[p])
+			}
+		}
+	}
+	if maxRunning < 2 {
+		t.Errorf("expected partitions to be handled in parallel, max running %d", maxRunning)
+	}
+
+	// commits of each partition must follow the offsets
+	last := make(map[string]int)
+	for _, id := range commits {
+		var p, offset int
+		fmt.Sscanf(id, "%d/%d", &p, &offset)
+		key := fmt.Sprint(p)
+		if prev, ok := last[key]; ok && offset <= prev {
+			t.Fatalf("partition %d committed out of order: %v", p, commits)
+		}
+		last[key] = offset
+	}
+	if len(commits) != partitions*perPartition {
+		t.Errorf("expected %d commits, got %d", partitions*perPartition, len(commits))
+	}
+}
@@ -3,9 +3,11 @@ package kp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/url"
 	"os"
+	"sync/atomic"
 
 	config "github.com/sing3demons/go-common-kp/kp/configs"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
@@ -33,9 +35,18 @@ type Context struct {
 	metaData logger.Metadata
 	conf     *config.Config
 	appLog   logger.LoggerService
+	metrics  *appMetrics
+
+	httpServices *httpServices
+	// httpRequest is the request served by an HTTP handler.
+	httpRequest *http.Request
+
+	responded atomic.Bool
 }
 type SubscribeFunc func(c *Context) error
 
+var errResponseAlreadySent = errors.New("response already sent")
+
 type Request interface {
 	Context() context.Context
 	Param(string) string
@@ -105,6 +116,11 @@ func newContext(w http.ResponseWriter, r Request, k kafka.Client, log LogService
 		TraceId:   traceID,
 		SpanId:    spanId,
 	}
+	if !isHTTP {
+		meta.Topic = r.Param("topic")
+		meta.Key = r.Param("key")
+		meta.ConsumerGroup = r.Param("consumer_group")
+	}
 	hostName, _ := os.Hostname()
 	ctx.metaData = meta
 
@@ -119,28 +135,12 @@ func newContext(w http.ResponseWriter, r Request, k kafka.Client, log LogService
 	}
 	kpLog.Init(customLog)
 	if !isHTTP {
-		topic := r.Param("topic")
-		summary := logger.LogEventTag{
-			Node:        "consumer",
-			Command:     topic,
-			Code:        "200",
-			Description: "",
+		consumed := []Request{r}
+		if batch, ok := r.(*batchRequest); ok {
+			consumed = batch.requests()
 		}
-		body, err := ctx.Body()
-		if err != nil {
-			summary.Code = "500"
-			summary.Description = err.Error()
-			kpLog.SetSummary(summary).Error(logger.NewConsuming(topic, "kafka"+"_consumer"), map[string]any{
-				"topic":  topic,
-				"broker": broker,
-				"error":  err.Error(),
-			})
-		} else {
-			kpLog.SetSummary(summary).Info(logger.NewConsuming(topic, "kafka"+"_consumer"), map[string]any{
-				"topic":  topic,
-				"broker": broker,
-				"body":   body,
-			})
+		for _, m := range consumed {
+			logConsumed(kpLog, m, broker)
 		}
 	} else {
 		body := map[string]any{}
@@ -172,6 +172,35 @@ func newContext(w http.ResponseWriter, r Request, k kafka.Client, log LogService
 	return ctx
 }
 
+// logConsumed writes the detail log and the summary sequence of a consumed message.
+func logConsumed(kpLog logger.CustomLoggerService, r Request, broker string) {
+	topic := r.Param("topic")
+	summary := logger.LogEventTag{
+		Node:        "consumer",
+		Command:     topic,
+		Code:        "200",
+		Description: "",
+	}
+	data := map[string]any{
+		"topic":     topic,
+		"broker":    broker,
+		"key":       r.Param("key"),
+		"partition": r.Param("partition"),
+		"offset":    r.Param("offset"),
+		"headers":   r.Headers(),
+	}
+	body, err := r.Body()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		data["error"] = err.Error()
+		kpLog.SetSummary(summary).Error(logger.NewConsuming(topic, "kafka"+"_consumer"), data)
+	} else {
+		data["body"] = body
+		kpLog.SetSummary(summary).Info(logger.NewConsuming(topic, "kafka"+"_consumer"), data)
+	}
+}
+
 type AppLogStruct struct {
 	LogType     string `json:"logType"`
 	LogLevel    string `json:"logLevel"`
@@ -248,25 +277,96 @@ func (c *Context) GetIncoming() IncomingReq {
 }
 
 func (c *Context) JSON(code int, v any) error {
-	if c.ResponseWriter != nil {
+	return c.writeJSON(code, v, "")
+}
 
-		c.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8") // set first
-		c.ResponseWriter.WriteHeader(code)                                               // then send status
+// Responded reports whether a response has already been written through the Context.
+func (c *Context) Responded() bool {
+	return c.responded.Load()
+}
 
-		err := json.NewEncoder(c.ResponseWriter).Encode(v)
+// claimResponse marks the response as sent; only the first caller gets true.
+func (c *Context) claimResponse() bool {
+	return c.responded.CompareAndSwap(false, true)
+}
 
-		if c.detail != nil {
-			if err != nil {
-				c.detail.AddField("CustomError", err.Error())
-			}
-			c.detail.Info(logger.NewOutbound("client", ""), v)
-			c.detail.End(code, "")
-		}
+// writeJSON sends v as the response and closes the summary log with message.
+func (c *Context) writeJSON(code int, v any, message string) error {
+	if c.ResponseWriter == nil {
+		return nil
+	}
+	if !c.claimResponse() {
+		return errResponseAlreadySent
 	}
 
+	err := encodeJSON(c.ResponseWriter, code, v)
+	c.logResponse(code, v, message, err)
+
 	return nil
 }
 
+func encodeJSON(w http.ResponseWriter, code int, v any) error {
+	w.Header().Set("Content-Type", MIMEApplicationJSON) // set first
+	w.WriteHeader(code)                                 // then send status
+
+	return json.NewEncoder(w).Encode(v)
+}
+
+// logResponse writes the outbound payload to the detail log and closes the summary log.
+func (c *Context) logResponse(code int, v any, message string, err error) {
+	if c.detail == nil {
+		return
+	}
+
+	if err != nil {
+		c.detail.AddField("CustomError", err.Error())
+	}
+	c.detail.Info(logger.NewOutbound("client", ""), v)
+	c.detail.End(code, message)
+}
+
+// sendError answers the request with the JSON error envelope of err and
+// classifies the summary log with its result code, result type and severity.
+func (c *Context) sendError(err error) error {
+	e := AsError(err)
+	c.classify(e)
+
+	return c.writeJSON(e.HTTPStatus, e.Response(), e.Message)
+}
+
+// closeSummary closes the summary log of a context that has no HTTP response,
+// such as a Kafka message, classifying the result from err. It does nothing if
+// the summary log was already closed.
+func (c *Context) closeSummary(err error) {
+	if c.detail == nil || !c.claimResponse() {
+		return
+	}
+
+	if err == nil {
+		c.detail.End(http.StatusOK, "")
+		return
+	}
+
+	e := AsError(err)
+	c.classify(e)
+	c.detail.End(e.HTTPStatus, e.Message)
+}
+
+// classify records the result of a failed request for the summary log.
+func (c *Context) classify(e *Error) {
+	if c.detail == nil {
+		return
+	}
+
+	c.detail.Update("AppResult", e.Message)
+	c.detail.Update("AppResultCode", e.Code)
+	c.detail.Update("AppResultType", e.ResultType)
+	c.detail.Update("Severity", e.Severity)
+	if e.Err != nil {
+		c.detail.AddField("Cause", e.Err.Error())
+	}
+}
+
 func (c *Context) LogAuto(masks ...logger.MaskingOptionDto) logger.CustomLoggerService {
 	if c.incoming.URL != "" && c.incoming.Method != "" {
 		c.detail.Info(logger.NewInbound("client", ""), c.incoming, masks...)
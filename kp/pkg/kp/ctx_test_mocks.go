@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 
 	config "github.com/sing3demons/go-common-kp/kp/configs"
@@ -15,11 +16,22 @@ import (
 type MockKafkaClient struct {
 	PublishCalls     []PublishCall
 	SubscribeCalls   []SubscribeCall
+	ForwardCalls     []ForwardCall
+	TransactionCalls int
+	ListTopicsResult []string
 	CreateTopicCalls []CreateTopicCall
 	DeleteTopicCalls []DeleteTopicCall
 	CloseCalls       int
+
+	EnsureTopicsCalls    [][]string
+	AlterPartitionsCalls []AlterPartitionsCall
+	DescribeTopicsResult []kafka.TopicDescription
+
 	PublishError     error
 	SubscribeError   error
+	ForwardError     error
+	TransactionError error
+	ListTopicsError  error
 	CreateTopicError error
 	DeleteTopicError error
 	CloseError       error
@@ -28,16 +40,29 @@ type MockKafkaClient struct {
 type PublishCall struct {
 	Topic   string
 	Message []byte
+	Key     []byte
+	Headers map[string]string
 }
 
 type SubscribeCall struct {
 	Topic string
 }
 
+type ForwardCall struct {
+	Topic   string
+	Message *kafka.Message
+	Headers map[string]string
+}
+
 type CreateTopicCall struct {
 	Name string
 }
 
+type AlterPartitionsCall struct {
+	Topic string
+	Count int
+}
+
 type DeleteTopicCall struct {
 	Name string
 }
@@ -47,6 +72,11 @@ func (m *MockKafkaClient) Publish(ctx context.Context, topic string, message []b
 	return m.PublishError
 }
 
+func (m *MockKafkaClient) PublishMessage(ctx context.Context, msg kafka.OutgoingMessage) error {
+	m.PublishCalls = append(m.PublishCalls, PublishCall{Topic: msg.Topic, Message: msg.Value, Key: msg.Key, Headers: msg.Headers})
+	return m.PublishError
+}
+
 func (m *MockKafkaClient) Subscribe(ctx context.Context, topic string) (*kafka.Message, error) {
 	m.SubscribeCalls = append(m.SubscribeCalls, SubscribeCall{Topic: topic})
 	if m.SubscribeError != nil {
@@ -55,11 +85,82 @@ func (m *MockKafkaClient) Subscribe(ctx context.Context, topic string) (*kafka.M
 	return &kafka.Message{}, nil
 }
 
+func (m *MockKafkaClient) Forward(ctx context.Context, topic string, msg *kafka.Message, headers map[string]string) error {
+	m.ForwardCalls = append(m.ForwardCalls, ForwardCall{Topic: topic, Message: msg, Headers: headers})
+	return m.ForwardError
+}
+
+// Transaction buffers the messages published by fn like the Kafka client: they
+// are recorded in PublishCalls, and the marked offsets committed, only if fn and
+// the simulated write (TransactionError) succeed.
+func (m *MockKafkaClient) Transaction(ctx context.Context, fn func(tx kafka.Tx) error) error {
+	m.TransactionCalls++
+
+	tx := &mockTx{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if m.TransactionError != nil {
+		return m.TransactionError
+	}
+	for _, msg := range tx.messages {
+		m.PublishCalls = append(m.PublishCalls, PublishCall{Topic: msg.Topic, Message: msg.Value, Key: msg.Key, Headers: msg.Headers})
+	}
+	for _, msg := range tx.consumed {
+		if msg.Committer != nil {
+			msg.Commit()
+		}
+	}
+	return nil
+}
+
+type mockTx struct {
+	messages []kafka.OutgoingMessage
+	consumed []*kafka.Message
+}
+
+func (tx *mockTx) Publish(topic string, message []byte) {
+	tx.PublishMessage(kafka.OutgoingMessage{Topic: topic, Value: message})
+}
+
+func (tx *mockTx) PublishMessage(msg kafka.OutgoingMessage) {
+	tx.messages = append(tx.messages, msg)
+}
+
+func (tx *mockTx) CommitOffset(msg *kafka.Message) {
+	tx.consumed = append(tx.consumed, msg)
+}
+
 func (m *MockKafkaClient) CreateTopic(ctx context.Context, name string) error {
 	m.CreateTopicCalls = append(m.CreateTopicCalls, CreateTopicCall{Name: name})
 	return m.CreateTopicError
 }
 
+func (m *MockKafkaClient) CreateTopics(ctx context.Context, topics ...kafka.TopicConfig) error {
+	for _, t := range topics {
+		m.CreateTopicCalls = append(m.CreateTopicCalls, CreateTopicCall{Name: t.Name})
+	}
+	return m.CreateTopicError
+}
+
+func (m *MockKafkaClient) EnsureTopics(ctx context.Context, names ...string) error {
+	m.EnsureTopicsCalls = append(m.EnsureTopicsCalls, names)
+	return m.CreateTopicError
+}
+
+func (m *MockKafkaClient) DescribeTopics(ctx context.Context, names ...string) ([]kafka.TopicDescription, error) {
+	return m.DescribeTopicsResult, m.ListTopicsError
+}
+
+func (m *MockKafkaClient) AlterPartitions(ctx context.Context, topic string, count int) error {
+	m.AlterPartitionsCalls = append(m.AlterPartitionsCalls, AlterPartitionsCall{Topic: topic, Count: count})
+	return m.CreateTopicError
+}
+
+func (m *MockKafkaClient) ListTopics(ctx context.Context) ([]string, error) {
+	return m.ListTopicsResult, m.ListTopicsError
+}
+
 func (m *MockKafkaClient) DeleteTopic(ctx context.Context, name string) error {
 	m.DeleteTopicCalls = append(m.DeleteTopicCalls, DeleteTopicCall{Name: name})
 	return m.DeleteTopicError
@@ -80,6 +181,9 @@ type MockLoggerService struct {
 	LogCalls    []string
 	ErrorfCalls []ErrorfCall
 	SyncCalls   int
+
+	// mu guards the calls: Kafka workers log concurrently
+	mu sync.Mutex
 }
 
 type DebugfCall struct {
@@ -98,34 +202,58 @@ type ErrorfCall struct {
 }
 
 func (m *MockLoggerService) Debugf(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.DebugfCalls = append(m.DebugfCalls, DebugfCall{Format: format, Args: args})
 }
 
 func (m *MockLoggerService) Debug(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.DebugCalls = append(m.DebugCalls, msg)
 }
 
 func (m *MockLoggerService) Logf(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.LogfCalls = append(m.LogfCalls, LogfCall{Format: format, Args: args})
 }
 
 func (m *MockLoggerService) Log(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.LogCalls = append(m.LogCalls, msg)
 }
 
 func (m *MockLoggerService) Info(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.InfoCalls = append(m.InfoCalls, msg)
 }
 
 func (m *MockLoggerService) Errorf(format string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.ErrorfCalls = append(m.ErrorfCalls, ErrorfCall{Format: format, Args: args})
 }
 
 func (m *MockLoggerService) Error(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.ErrorCalls = append(m.ErrorCalls, msg)
 }
 
 func (m *MockLoggerService) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.SyncCalls++
 	return nil
 }
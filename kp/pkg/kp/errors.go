@@ -0,0 +1,155 @@
+package kp
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/validator"
+)
+
+// Error is a handler error that carries everything needed to answer the client
+// and to close the summary log: the HTTP status, the business result code, the
+// summary result type (logger.CLIENT_ERROR, logger.BUSINESS_ERROR, ...) and the
+// severity. Handlers and middlewares return it instead of writing the response.
+type Error struct {
+	HTTPStatus int
+	Code       string
+	Message    string
+	ResultType string
+	Severity   string
+	Details    any
+	Err        error
+}
+
+// ErrorResponse is the JSON envelope written for a failed request.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// NewError creates an Error for the given HTTP status. An empty code defaults to
+// the status padded to a five digit result code (404 -> "40400"); result type
+// and severity are derived from the status class.
+func NewError(httpStatus int, code, message string) *Error {
+	if code == "" {
+		code = logger.ConvertTTTTT(strconv.Itoa(httpStatus))
+	}
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+
+	e := &Error{
+		HTTPStatus: httpStatus,
+		Code:       code,
+		Message:    message,
+		ResultType: logger.CLIENT_ERROR,
+		Severity:   logger.MINOR_ISSUE,
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		e.ResultType = logger.SYSTEM_ERROR
+		e.Severity = logger.MAJOR_ISSUE
+	}
+
+	return e
+}
+
+func ErrBadRequest(code, message string) *Error {
+	return NewError(http.StatusBadRequest, code, message)
+}
+
+func ErrUnauthorized(code, message string) *Error {
+	return NewError(http.StatusUnauthorized, code, message)
+}
+
+func ErrForbidden(code, message string) *Error {
+	return NewError(http.StatusForbidden, code, message)
+}
+
+func ErrNotFound(code, message string) *Error {
+	return NewError(http.StatusNotFound, code, message)
+}
+
+func ErrConflict(code, message string) *Error {
+	return NewError(http.StatusConflict, code, message)
+}
+
+// ErrBusiness reports a request that was valid but rejected by a business rule.
+func ErrBusiness(httpStatus int, code, message string) *Error {
+	e := NewError(httpStatus, code, message)
+	e.ResultType = logger.BUSINESS_ERROR
+	e.Severity = logger.NOTICE
+	return e
+}
+
+// ErrInternal wraps an unexpected failure as a 500 SYSTEM_ERROR.
+func ErrInternal(err error) *Error {
+	return NewError(http.StatusInternalServerError, "", "").WithCause(err)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCause records the underlying error; it is logged but not sent to the client.
+func (e *Error) WithCause(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// WithDetails attaches extra data to the "details" field of the response.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+func (e *Error) WithSeverity(severity string) *Error {
+	e.Severity = severity
+	return e
+}
+
+func (e *Error) WithResultType(resultType string) *Error {
+	e.ResultType = resultType
+	return e
+}
+
+// Response returns the JSON envelope sent to the client.
+func (e *Error) Response() ErrorResponse {
+	return ErrorResponse{
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	}
+}
+
+// AsError converts any error into an *Error. Validation errors of Bind become
+// a 400 CLIENT_ERROR listing the failed fields in its details; other errors
+// that are not (and do not wrap) an *Error become a 500 SYSTEM_ERROR, as by
+// ErrInternal: their message may hold internal details, so it is logged but
+// not sent to the client.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		return ErrBadRequest("", "Validation failed").WithDetails(invalid).WithCause(err)
+	}
+
+	return ErrInternal(err)
+}
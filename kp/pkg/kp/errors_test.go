@@ -0,0 +1,144 @@
+package kp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/validator"
+)
+
+func TestHandlerErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		message    string
+		resultType string
+		severity   string
+	}{
+		{
+			name:       "client error",
+			err:        ErrNotFound("40401", "order not found"),
+			status:     http.StatusNotFound,
+			code:       "40401",
+			message:    "order not found",
+			resultType: logger.CLIENT_ERROR,
+			severity:   logger.MINOR_ISSUE,
+		},
+		{
+			name:       "wrapped business error",
+			err:        fmt.Errorf("checkout: %w", ErrBusiness(http.StatusUnprocessableEntity, "42201", "insufficient balance")),
+			status:     http.StatusUnprocessableEntity,
+			code:       "42201",
+			message:    "insufficient balance",
+			resultType: logger.BUSINESS_ERROR,
+			severity:   logger.NOTICE,
+		},
+		{
+			name:       "validation error",
+			err:        validator.ValidationErrors{{Field: "email", Rule: "required", Message: "email is required"}},
+			status:     http.StatusBadRequest,
+			code:       "40000",
+			message:    "Validation failed",
+			resultType: logger.CLIENT_ERROR,
+			severity:   logger.MINOR_ISSUE,
+		},
+		{
+			name:       "plain error",
+			err:        errors.New("db unavailable"),
+			status:     http.StatusInternalServerError,
+			code:       "50000",
+			message:    "Internal Server Error",
+			resultType: logger.SYSTEM_ERROR,
+			severity:   logger.MAJOR_ISSUE,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.Get("/orders", func(c *Context) error { return tt.err })
+
+			rec := serve(app, http.MethodGet, "/orders")
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+
+			var body ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+			}
+			if body.Code != tt.code {
+				t.Errorf("expected code %q, got %q", tt.code, body.Code)
+			}
+			if body.Message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, body.Message)
+			}
+
+			summaryLog := app.SummaryLog.(*MockLoggerService)
+			if len(summaryLog.InfoCalls) != 1 {
+				t.Fatalf("expected 1 summary log, got %d", len(summaryLog.InfoCalls))
+			}
+
+			var summary logger.LogDto
+			if err := json.Unmarshal([]byte(summaryLog.InfoCalls[0]), &summary); err != nil {
+				t.Fatalf("invalid summary log: %v", err)
+			}
+			if summary.AppResultCode != tt.code {
+				t.Errorf("expected appResultCode %q, got %q", tt.code, summary.AppResultCode)
+			}
+			if summary.AppResultType != tt.resultType {
+				t.Errorf("expected appResultType %q, got %q", tt.resultType, summary.AppResultType)
+			}
+			if summary.Severity != tt.severity {
+				t.Errorf("expected severity %q, got %q", tt.severity, summary.Severity)
+			}
+		})
+	}
+}
+
+func TestBindValidationErrorResponse(t *testing.T) {
+	type item struct {
+		SKU string `json:"sku" validate:"required"`
+	}
+	type order struct {
+		Email string `json:"email" validate:"required,email"`
+		Items []item `json:"items" validate:"min=1"`
+	}
+
+	app := newTestApp(t)
+	app.Post("/orders", func(c *Context) error {
+		var o order
+		if err := c.Bind(&o); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, o)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"email":"nope","items":[{"sku":""}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.httpServer.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Code    string                 `json:"code"`
+		Details []validator.FieldError `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	if len(body.Details) != 2 || body.Details[0].Field != "email" || body.Details[1].Field != "items[0].sku" {
+		t.Errorf("expected the email and sku field errors, got %+v", body.Details)
+	}
+}
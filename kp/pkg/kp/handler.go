@@ -3,9 +3,7 @@ package kp
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
-	"runtime/debug"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -18,77 +16,146 @@ type Handler func(c *Context) error
 
 type handler struct {
 	function       Handler
+	middlewares    func() []Middleware
 	requestTimeout time.Duration
 	kafkaClient    kafka.Client
 	logService     LogService
 	conf           *config.Config
+	metrics        *appMetrics
+	httpServices   *httpServices
 }
 
+// defaultRequestTimeout is used when neither the route nor the config sets one.
+const defaultRequestTimeout = 10 * time.Second
+
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	isWebSocket := websocket.IsWebSocketUpgrade(r)
+
+	var guard *guardedResponseWriter
+	if !isWebSocket {
+		h.extendWriteDeadline(w, r)
+		guard = newGuardedResponseWriter(w)
+		w = guard
+	}
+
+	defer h.observe(r, guard, time.Now())
 
 	c := newContext(w, goHTTP.NewRequest(r), h.kafkaClient, h.logService, h.conf)
+	c.metrics = h.metrics
+	c.httpServices = h.httpServices
+	c.httpRequest = r
 	// traceID := trace.SpanFromContext(r.Context()).SpanContext().TraceID().String()
 
-	if websocket.IsWebSocketUpgrade(r) {
+	if isWebSocket {
 		// If the request is a WebSocket upgrade, do not apply the timeout
 		c.Context = r.Context()
-	} else if h.requestTimeout != 0 {
+	} else if h.requestTimeout > 0 {
 		ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
 		defer cancel()
 
 		c.Context = ctx
 	}
 
-	done := make(chan struct{})
-	panicked := make(chan struct{})
-
-	var (
-		err error
-	)
+	done := make(chan error, 1)
+	panicked := make(chan *Error, 1)
 
 	go func() {
 		defer func() {
-			panicRecoveryHandler(recover(), panicked)
+			if re := recover(); re != nil {
+				panicked <- recoverPanic(c, re, "http")
+			}
 		}()
-		// Execute the handler function
-		err = h.function(c)
-		// h.logError(traceID, err)
-		close(done)
+		// Execute the handler function behind its middleware stack
+		done <- h.chain()(c)
 	}()
 
+	var err error
+
 	select {
 	case <-c.Context.Done():
-		// If the context's deadline has been exceeded, return a timeout error response
-		if errors.Is(c.Err(), context.DeadlineExceeded) {
-			err = errors.New("request timed out")
+		// The handler is still running: stop it from writing and answer for it
+		if guard != nil {
+			h.abort(c, guard)
 		}
-	case <-done:
+		return
+	case err = <-done:
 		handleWebSocketUpgrade(r)
-	case <-panicked:
-		err = errors.New("internal server error")
+	case e := <-panicked:
+		err = e
 	}
 
 	// Handler function completed
 	if err != nil {
-		c.ResponseWriter.Write([]byte(err.Error()))
+		if c.Responded() {
+			c.appLog.Errorf("handler returned an error after responding: %v", err)
+			return
+		}
+		c.sendError(err)
+		return
+	}
+
+}
+
+// extendWriteDeadline lifts the write timeout of the serving http.Server for
+// this request when the route may run longer than it allows: up to the request
+// timeout of the route, or without limit when the route has none, such as a
+// stream.
+func (h handler) extendWriteDeadline(w http.ResponseWriter, r *http.Request) {
+	srv, _ := r.Context().Value(http.ServerContextKey).(*http.Server)
+	if srv == nil || srv.WriteTimeout <= 0 {
 		return
 	}
 
+	rc := http.NewResponseController(w)
+	switch {
+	case h.requestTimeout <= 0:
+		_ = rc.SetWriteDeadline(time.Time{})
+	case h.requestTimeout+time.Second > srv.WriteTimeout:
+		_ = rc.SetWriteDeadline(time.Now().Add(h.requestTimeout + time.Second))
+	}
 }
-func panicRecoveryHandler(re any, panicked chan struct{}) {
-	if re == nil {
+
+// abort ends a request whose context is done while its handler is still running.
+// Later writes from the handler are dropped; on deadline the client receives a
+// 504 JSON envelope and the summary log is closed as a gateway timeout.
+func (h handler) abort(c *Context, guard *guardedResponseWriter) {
+	if !errors.Is(c.Context.Err(), context.DeadlineExceeded) {
+		// the client went away, there is nobody left to answer
+		guard.expire(statusClientClosedRequest, nil)
 		return
 	}
 
-	close(panicked)
-	panicLog := struct {
-		Error      string `json:"error,omitempty"`
-		StackTrace string `json:"stack_trace,omitempty"`
-	}{
-		Error:      fmt.Sprint(re),
-		StackTrace: string(debug.Stack()),
+	e := NewError(http.StatusGatewayTimeout, "", "request timed out")
+	claimed := c.claimResponse()
+	guard.expire(e.HTTPStatus, func(w http.ResponseWriter) {
+		encodeJSON(w, e.HTTPStatus, e.Response())
+	})
+
+	if claimed {
+		c.classify(e)
+		c.logResponse(e.HTTPStatus, e.Response(), e.Message, nil)
+	}
+}
+
+// observe records the request in the HTTP metrics once it has been served.
+func (h handler) observe(r *http.Request, guard *guardedResponseWriter, start time.Time) {
+	status := http.StatusSwitchingProtocols
+	if guard != nil {
+		status = guard.Status()
+		if status == 0 {
+			// the handler returned without writing: net/http sends an empty 200
+			status = http.StatusOK
+		}
+	}
+	h.metrics.observeHTTP(r, status, start)
+}
+
+// chain returns the route handler wrapped by its middlewares.
+func (h handler) chain() Handler {
+	if h.middlewares == nil {
+		return h.function
 	}
-	fmt.Println(panicLog)
+	return chainMiddleware(h.function, h.middlewares()...)
 }
 
 func handleWebSocketUpgrade(r *http.Request) {
@@ -0,0 +1,307 @@
+package kp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/validator"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	app := newTestApp(t)
+
+	finished := make(chan error, 1)
+	app.Get("/slow", func(c *Context) error {
+		<-c.Done()
+		time.Sleep(10 * time.Millisecond)
+		// written after the deadline: must not reach the client
+		finished <- c.JSON(http.StatusOK, "late")
+		return nil
+	}).Timeout(20 * time.Millisecond)
+
+	rec := serve(app, http.MethodGet, "/slow")
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status 504, got %d", rec.Code)
+	}
+
+	select {
+	case err := <-finished:
+		if err != errResponseAlreadySent {
+			t.Errorf("expected late write to be rejected, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not finish")
+	}
+
+	var body ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	if body.Code != "50400" {
+		t.Errorf("expected code 50400, got %q", body.Code)
+	}
+
+	summaryLog := app.SummaryLog.(*MockLoggerService)
+	if len(summaryLog.InfoCalls) != 1 {
+		t.Fatalf("expected 1 summary log, got %d", len(summaryLog.InfoCalls))
+	}
+
+	var summary logger.LogDto
+	if err := json.Unmarshal([]byte(summaryLog.InfoCalls[0]), &summary); err != nil {
+		t.Fatalf("invalid summary log: %v", err)
+	}
+	if summary.AppResultHttpStatus != "504" {
+		t.Errorf("expected appResultHttpStatus 504, got %q", summary.AppResultHttpStatus)
+	}
+	if summary.AppResultType != logger.SYSTEM_ERROR {
+		t.Errorf("expected appResultType %q, got %q", logger.SYSTEM_ERROR, summary.AppResultType)
+	}
+}
+
+func TestTimedOutHandlerKeepsLogging(t *testing.T) {
+	app := newTestApp(t)
+
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	app.Get("/slow", func(c *Context) error {
+		defer close(finished)
+		// logs while abort closes the summary log: run with -race
+		for {
+			select {
+			case <-stop:
+				return nil
+			default:
+				c.Log().Info(logger.NewOutbound("inventory", "reserve"), map[string]any{"sku": "A1"})
+				c.Log().SetSummary(logger.EventTag("inventory", "reserve", "200", ""))
+			}
+		}
+	}).Timeout(20 * time.Millisecond)
+
+	rec := serve(app, http.MethodGet, "/slow")
+	close(stop)
+	<-finished
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", rec.Code)
+	}
+	if calls := app.SummaryLog.(*MockLoggerService).InfoCalls; len(calls) != 1 {
+		t.Errorf("expected 1 summary log, got %d", len(calls))
+	}
+}
+
+func TestRouteTimeoutFromConfig(t *testing.T) {
+	app := newTestApp(t)
+	app.conf.Server.RequestTimeout = 20 * time.Millisecond
+
+	app.Get("/slow", func(c *Context) error {
+		<-c.Done()
+		return nil
+	})
+	app.Get("/no-timeout", func(c *Context) error {
+		time.Sleep(40 * time.Millisecond)
+		return c.JSON(http.StatusOK, "ok")
+	}).Timeout(0)
+
+	if rec := serve(app, http.MethodGet, "/slow"); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", rec.Code)
+	}
+	if rec := serve(app, http.MethodGet, "/no-timeout"); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+}
+
+func TestRouteTimeoutLiftsWriteDeadline(t *testing.T) {
+	app := newTestApp(t)
+	app.Get("/slow", func(c *Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return c.String(http.StatusOK, "slow")
+	}).Timeout(time.Second)
+	app.Get("/stream", func(c *Context) error {
+		time.Sleep(100 * time.Millisecond)
+		return c.String(http.StatusOK, "stream")
+	}).Timeout(0)
+
+	server := httptest.NewUnstartedServer(app.httpServer.router)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	// both routes outlive the write timeout the server is configured with
+	for _, path := range []string{"/slow", "/stream"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("expected %s to outlive the write timeout: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected status 200 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+// deadlineRecorder records the write deadlines set through http.ResponseController.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (r *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	r.deadlines = append(r.deadlines, deadline)
+	return nil
+}
+
+func TestExtendWriteDeadline(t *testing.T) {
+	tests := []struct {
+		name         string
+		writeTimeout time.Duration
+		routeTimeout time.Duration
+		want         string
+	}{
+		{name: "fits in the write timeout", writeTimeout: 5 * time.Second, routeTimeout: time.Second, want: "kept"},
+		{name: "longer than the write timeout", writeTimeout: 5 * time.Second, routeTimeout: 10 * time.Second, want: "extended"},
+		{name: "without route timeout", writeTimeout: 5 * time.Second, want: "lifted"},
+		{name: "without write timeout", routeTimeout: 10 * time.Second, want: "kept"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &http.Server{WriteTimeout: tt.writeTimeout}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(context.WithValue(req.Context(), http.ServerContextKey, srv))
+			rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+			handler{requestTimeout: tt.routeTimeout}.extendWriteDeadline(rec, req)
+
+			got := "kept"
+			if len(rec.deadlines) == 1 {
+				got = "extended"
+				if rec.deadlines[0].IsZero() {
+					got = "lifted"
+				}
+			}
+			if got != tt.want {
+				t.Errorf("expected the write deadline %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	app := newTestApp(t)
+	app.Get("/panic", func(c *Context) error {
+		panic("boom")
+	})
+
+	rec := serve(app, http.MethodGet, "/panic")
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", rec.Code)
+	}
+	if got := app.metrics.panics.Value("http"); got != 1 {
+		t.Errorf("expected 1 recovered panic, got %v", got)
+	}
+
+	detailLog := app.DetailLog.(*MockLoggerService)
+	if len(detailLog.ErrorCalls) != 1 {
+		t.Fatalf("expected 1 exception detail log, got %d", len(detailLog.ErrorCalls))
+	}
+	var exception logger.LogDto
+	if err := json.Unmarshal([]byte(detailLog.ErrorCalls[0]), &exception); err != nil {
+		t.Fatalf("invalid detail log: %v", err)
+	}
+	if exception.Action != string(logger.EXCEPTION) {
+		t.Errorf("expected action %q, got %q", logger.EXCEPTION, exception.Action)
+	}
+
+	summaryLog := app.SummaryLog.(*MockLoggerService)
+	if len(summaryLog.InfoCalls) != 1 {
+		t.Fatalf("expected 1 summary log, got %d", len(summaryLog.InfoCalls))
+	}
+	var summary logger.LogDto
+	if err := json.Unmarshal([]byte(summaryLog.InfoCalls[0]), &summary); err != nil {
+		t.Fatalf("invalid summary log: %v", err)
+	}
+	if summary.AppResultType != logger.SYSTEM_ERROR || summary.Severity != logger.CRITICAL_ISSUE {
+		t.Errorf("expected SYSTEM_ERROR/CRITICAL_ISSUE, got %s/%s", summary.AppResultType, summary.Severity)
+	}
+}
+
+func TestBindPathQueryAndHeaders(t *testing.T) {
+	type request struct {
+		Tenant string    `path:"tenant"`
+		ID     int64     `path:"id"`
+		Page   *int      `query:"page"`
+		Limit  *int      `query:"limit"`
+		Tags   []string  `query:"tag"`
+		Since  time.Time `query:"since"`
+		Active bool      `query:"active"`
+		Region string    `header:"x-tenant-region"`
+		Note   string    `json:"note"`
+	}
+
+	app := newTestApp(t)
+	var got request
+	app.Put("/tenants/{tenant}/orders/{id}", func(c *Context) error {
+		if err := c.Bind(&got); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, nil)
+	})
+
+	send := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant-Region", "eu")
+		rec := httptest.NewRecorder()
+		app.httpServer.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("/tenants/acme/orders/42?page=2&tag=a&tag=b&since=2024-01-02&active=true", `{"note":"gift"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got.Tenant != "acme" || got.ID != 42 || got.Region != "eu" || got.Note != "gift" || !got.Active {
+		t.Errorf("unexpected binding %+v", got)
+	}
+	if got.Page == nil || *got.Page != 2 || got.Limit != nil {
+		t.Errorf("expected page set and limit left nil, got %v and %v", got.Page, got.Limit)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "b" {
+		t.Errorf("expected both tags, got %v", got.Tags)
+	}
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !got.Since.Equal(want) {
+		t.Errorf("expected since %s, got %s", want, got.Since)
+	}
+
+	// keys of the body matching tagged fields must not override the URL and headers
+	got = request{}
+	rec = send("/tenants/acme/orders/42", `{"note":"gift","id":7,"tenant":"other","Region":"us","limit":9}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got.ID != 42 || got.Tenant != "acme" || got.Region != "eu" || got.Limit != nil || got.Note != "gift" {
+		t.Errorf("expected the body to set only the untagged fields, got %+v", got)
+	}
+
+	rec = send("/tenants/acme/orders/42?page=two", `{"note":"gift"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an invalid page, got %d", rec.Code)
+	}
+	var body struct {
+		Details []validator.FieldError `json:"details"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", rec.Body.String(), err)
+	}
+	if len(body.Details) != 1 || body.Details[0].Field != "page" {
+		t.Errorf("expected the page field error, got %+v", body.Details)
+	}
+}
@@ -0,0 +1,180 @@
+package kp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+const (
+	healthLivePath  = "/health/live"
+	healthReadyPath = "/health/ready"
+
+	healthStatusUp   = "UP"
+	healthStatusDown = "DOWN"
+
+	defaultHealthCheckTimeout = 5 * time.Second
+)
+
+var errShuttingDown = errors.New("application is shutting down")
+
+// HealthChecker checks a dependency the application needs to serve traffic.
+// Check returns nil when the dependency is healthy.
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type healthCheckFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// NewHealthChecker adapts a function to a HealthChecker.
+func NewHealthChecker(name string, fn func(ctx context.Context) error) HealthChecker {
+	return healthCheckFunc{name: name, fn: fn}
+}
+
+func (h healthCheckFunc) Name() string                    { return h.name }
+func (h healthCheckFunc) Check(ctx context.Context) error { return h.fn(ctx) }
+
+// HealthReport is the JSON body of the health endpoints.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Reason string                       `json:"reason,omitempty"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the outcome of a single HealthChecker.
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// health serves liveness and readiness. Liveness only tells the process is
+// running; readiness runs every checker and fails while shutting down so load
+// balancers stop routing traffic before the server stops.
+type health struct {
+	mu           sync.RWMutex
+	checkers     []HealthChecker
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func newHealth() *health {
+	return &health{timeout: defaultHealthCheckTimeout}
+}
+
+func (h *health) add(checkers ...HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checkers = append(h.checkers, checkers...)
+}
+
+// ready runs every checker concurrently and aggregates the results.
+func (h *health) ready(ctx context.Context) HealthReport {
+	if h.shuttingDown.Load() {
+		return HealthReport{Status: healthStatusDown, Reason: errShuttingDown.Error()}
+	}
+
+	h.mu.RLock()
+	checkers := append([]HealthChecker(nil), h.checkers...)
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	results := make([]HealthCheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker HealthChecker) {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, checker)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: healthStatusUp}
+	if len(checkers) > 0 {
+		report.Checks = make(map[string]HealthCheckResult, len(checkers))
+	}
+	for i, checker := range checkers {
+		report.Checks[checker.Name()] = results[i]
+		if results[i].Status != healthStatusUp {
+			report.Status = healthStatusDown
+		}
+	}
+
+	return report
+}
+
+func runHealthCheck(ctx context.Context, checker HealthChecker) (result HealthCheckResult) {
+	start := time.Now()
+	defer func() {
+		if re := recover(); re != nil {
+			result = HealthCheckResult{Status: healthStatusDown, Error: "panic during health check"}
+		}
+		result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	if err := checker.Check(ctx); err != nil {
+		return HealthCheckResult{Status: healthStatusDown, Error: err.Error()}
+	}
+	return HealthCheckResult{Status: healthStatusUp}
+}
+
+func (h *health) liveHandler(w http.ResponseWriter, _ *http.Request) {
+	writeHealthReport(w, HealthReport{Status: healthStatusUp})
+}
+
+func (h *health) readyHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, h.ready(r.Context()))
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	code := http.StatusOK
+	if report.Status != healthStatusUp {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// kafkaHealthChecker checks that the Kafka cluster controller is reachable.
+type kafkaHealthChecker struct {
+	client kafka.Client
+}
+
+func (kafkaHealthChecker) Name() string {
+	return "kafka"
+}
+
+func (k kafkaHealthChecker) Check(ctx context.Context) error {
+	pinger, ok := k.client.(kafka.Pinger)
+	if !ok {
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pinger.Ping(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,61 @@
+package kp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	app := newTestApp(t)
+
+	dbErr := error(nil)
+	app.AddHealthChecker(
+		NewHealthChecker("db", func(ctx context.Context) error { return dbErr }),
+		NewHealthChecker("cache", func(ctx context.Context) error { return nil }),
+	)
+
+	if rec := serve(app, http.MethodGet, "/health/live"); rec.Code != http.StatusOK {
+		t.Fatalf("expected live status 200, got %d", rec.Code)
+	}
+
+	report := func() (int, HealthReport) {
+		rec := serve(app, http.MethodGet, "/health/ready")
+		var r HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+			t.Fatalf("invalid health report %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, r
+	}
+
+	code, r := report()
+	if code != http.StatusOK || r.Status != healthStatusUp || len(r.Checks) != 2 {
+		t.Fatalf("expected healthy report, got %d %+v", code, r)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, r = report()
+	if code != http.StatusServiceUnavailable || r.Status != healthStatusDown {
+		t.Fatalf("expected unhealthy report, got %d %+v", code, r)
+	}
+	if got := r.Checks["db"]; got.Status != healthStatusDown || got.Error != "connection refused" {
+		t.Errorf("unexpected db check %+v", got)
+	}
+	if got := r.Checks["cache"]; got.Status != healthStatusUp {
+		t.Errorf("unexpected cache check %+v", got)
+	}
+
+	dbErr = nil
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	code, r = report()
+	if code != http.StatusServiceUnavailable || r.Reason != errShuttingDown.Error() {
+		t.Errorf("expected readiness to fail while shutting down, got %d %+v", code, r)
+	}
+	if rec := serve(app, http.MethodGet, "/health/live"); rec.Code != http.StatusOK {
+		t.Errorf("expected live status 200 while shutting down, got %d", rec.Code)
+	}
+}
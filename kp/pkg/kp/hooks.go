@@ -0,0 +1,48 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Hook is a lifecycle callback registered with OnStart or OnShutdown.
+type Hook func(ctx context.Context) error
+
+// OnStart registers hooks run, in order, by Start before the HTTP server and
+// the Kafka consumers are started. If a hook fails the remaining ones are
+// skipped, the shutdown sequence runs, with every OnShutdown hook, and Start
+// returns without serving.
+func (a *App) OnStart(hooks ...Hook) {
+	a.onStart = append(a.onStart, hooks...)
+}
+
+// OnShutdown registers hooks run, in order, during Shutdown once in-flight HTTP
+// requests and Kafka handlers have finished, and before the Kafka client is closed.
+// Every hook runs even if a previous one failed.
+//
+// Shutdown hooks are not paired with start hooks: they also run when a start
+// hook failed, so they must tolerate resources that were never started, e.g.
+// by checking for a nil connection before closing it.
+func (a *App) OnShutdown(hooks ...Hook) {
+	a.onShutdown = append(a.onShutdown, hooks...)
+}
+
+func (a *App) runStartHooks(ctx context.Context) error {
+	for i, hook := range a.onStart {
+		if err := hook(ctx); err != nil {
+			return fmt.Errorf("start hook %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (a *App) runShutdownHooks(ctx context.Context) error {
+	var err error
+	for i, hook := range a.onShutdown {
+		if e := hook(ctx); e != nil {
+			err = errors.Join(err, fmt.Errorf("shutdown hook %d: %w", i+1, e))
+		}
+	}
+	return err
+}
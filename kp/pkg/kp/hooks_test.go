@@ -0,0 +1,59 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	app := newTestApp(t)
+
+	var calls []string
+	hook := func(name string, err error) Hook {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	app.OnStart(hook("db", nil), hook("cache", errors.New("cache unavailable")), hook("discovery", nil))
+	app.OnShutdown(hook("close-db", nil), hook("close-cache", errors.New("already closed")))
+
+	// a failing start hook aborts Start before any server is run
+	app.Start()
+
+	want := []string{"db", "cache", "close-db", "close-cache"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected hooks %v, got %v", want, calls)
+	}
+
+	appLog := app.AppLog.(*MockLoggerService)
+	if len(appLog.ErrorfCalls) == 0 || !strings.Contains(fmt.Sprintf(appLog.ErrorfCalls[0].Format, appLog.ErrorfCalls[0].Args...), "cache unavailable") {
+		t.Errorf("expected the start failure to be logged, got %v", appLog.ErrorfCalls)
+	}
+	if !app.health.shuttingDown.Load() {
+		t.Error("expected the application to be shut down")
+	}
+}
+
+func TestShutdownHooksError(t *testing.T) {
+	app := newTestApp(t)
+
+	closed := 0
+	app.OnShutdown(
+		func(ctx context.Context) error { return errors.New("flush failed") },
+		func(ctx context.Context) error { closed++; return nil },
+	)
+
+	err := app.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "flush failed") {
+		t.Fatalf("expected shutdown hook error, got %v", err)
+	}
+	if closed != 1 {
+		t.Errorf("expected every shutdown hook to run, got %d", closed)
+	}
+}
@@ -0,0 +1,403 @@
+package kp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/resilience"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// HTTPService describes a downstream REST service called through
+// Context.HTTPClient. Every call is written to the detail log as an
+// HTTP_REQUEST and an HTTP_RESPONSE action and added to the summary log as an
+// event of node Name.
+type HTTPService struct {
+	Name    string
+	BaseURL string
+	// Timeout bounds each call, on top of the deadline of the Context.
+	Timeout time.Duration
+	// Headers are sent with every request.
+	Headers map[string]string
+	// Masks are applied to the logged requests and responses. Credential
+	// headers, such as Authorization and X-Api-Key, are always redacted.
+	Masks []logger.MaskingOptionDto
+	// Client sends the requests. Its transport is wrapped to create client
+	// spans and propagate the trace context; it defaults to http.DefaultTransport.
+	Client *http.Client
+	// Resilience retries the calls of the service and protects it with a
+	// circuit breaker, a bulkhead and per-attempt timeouts. The policy logs
+	// to the app log and exports its metrics under the service name.
+	Resilience *resilience.Config
+
+	policy *resilience.Policy
+}
+
+// HTTPRequest is a request sent by HTTPClient.Do.
+type HTTPRequest struct {
+	Method string
+	// Path is joined to the BaseURL of the service; a full URL is used as is.
+	Path    string
+	Query   url.Values
+	Headers map[string]string
+	// Body is sent as is when it is a []byte or a string, as JSON otherwise.
+	Body any
+	// Command names the call in the summary log. It defaults to the path.
+	Command string
+}
+
+// HTTPResponse is the response to a call of an HTTPClient.
+type HTTPResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Bind decodes the JSON body of the response into v.
+func (r *HTTPResponse) Bind(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// HTTPClient calls an HTTPService on behalf of a Context.
+type HTTPClient struct {
+	ctx     *Context
+	service *HTTPService
+}
+
+// httpServices holds the services registered with App.AddHTTPService.
+type httpServices struct {
+	mu       sync.RWMutex
+	services map[string]*HTTPService
+}
+
+func newHTTPServices() *httpServices {
+	return &httpServices{services: make(map[string]*HTTPService)}
+}
+
+func (s *httpServices) add(svc HTTPService, opts ...resilience.Option) {
+	svc.Client = tracedClient(svc.Name, svc.Client)
+	if svc.Resilience != nil {
+		svc.policy = resilience.New(svc.Name, *svc.Resilience, opts...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services[svc.Name] = &svc
+}
+
+// get returns the service registered as name, or a service without base URL
+// if there is none.
+func (s *httpServices) get(name string) *HTTPService {
+	if s != nil {
+		s.mu.RLock()
+		svc, ok := s.services[name]
+		s.mu.RUnlock()
+		if ok {
+			return svc
+		}
+	}
+	return &HTTPService{Name: name, Client: tracedClient(name, nil)}
+}
+
+// tracedClient returns a copy of client whose transport creates client spans.
+func tracedClient(name string, client *http.Client) *http.Client {
+	c := &http.Client{}
+	if client != nil {
+		*c = *client
+	}
+
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c.Transport = otelhttp.NewTransport(transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return name + " " + r.Method
+		}),
+	)
+	return c
+}
+
+// HTTPClient returns a client of the service registered as name with
+// App.AddHTTPService. An unknown name gives a client without base URL whose
+// calls are logged under that name.
+func (c *Context) HTTPClient(name string) *HTTPClient {
+	return &HTTPClient{ctx: c, service: c.httpServices.get(name)}
+}
+
+func (h *HTTPClient) Get(path string, query url.Values) (*HTTPResponse, error) {
+	return h.Do(HTTPRequest{Method: http.MethodGet, Path: path, Query: query})
+}
+
+func (h *HTTPClient) Post(path string, body any) (*HTTPResponse, error) {
+	return h.Do(HTTPRequest{Method: http.MethodPost, Path: path, Body: body})
+}
+
+func (h *HTTPClient) Put(path string, body any) (*HTTPResponse, error) {
+	return h.Do(HTTPRequest{Method: http.MethodPut, Path: path, Body: body})
+}
+
+func (h *HTTPClient) Patch(path string, body any) (*HTTPResponse, error) {
+	return h.Do(HTTPRequest{Method: http.MethodPatch, Path: path, Body: body})
+}
+
+func (h *HTTPClient) Delete(path string) (*HTTPResponse, error) {
+	return h.Do(HTTPRequest{Method: http.MethodDelete, Path: path})
+}
+
+// Do sends req with the transaction, session and trace headers of the
+// Context. A response is returned whatever its status code; the error reports
+// only requests that got no response.
+//
+// With a Resilience config, failed attempts, including 429 and 5xx
+// responses, are retried under the policy of the service and the last
+// response is returned. Every attempt is logged.
+func (h *HTTPClient) Do(req HTTPRequest) (*HTTPResponse, error) {
+	svc := h.service
+	command := req.Command
+	if command == "" {
+		command = req.Path
+	}
+
+	body, contentType, err := h.encodeBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *HTTPResponse
+	err = svc.policy.Execute(h.ctx, func(ctx context.Context) error {
+		var err error
+		result, err = h.send(ctx, req, command, body, contentType)
+		if err == nil && retryableStatus(result.StatusCode) {
+			return &statusError{service: svc.Name, code: result.StatusCode}
+		}
+		return err
+	})
+
+	var status *statusError
+	switch {
+	case err == nil, errors.As(err, &status):
+		return result, nil
+	case errors.Is(err, resilience.ErrCircuitOpen), errors.Is(err, resilience.ErrBulkheadFull):
+		h.log().SetSummary(logger.LogEventTag{
+			Node:        svc.Name,
+			Command:     command,
+			Code:        strconv.Itoa(http.StatusServiceUnavailable),
+			Description: err.Error(),
+		}).Error(logger.NewHTTPResponse(svc.Name, command), map[string]any{
+			"error": err.Error(),
+		}, svc.Masks...)
+		return nil, fmt.Errorf("call %s: %w", svc.Name, err)
+	}
+	return result, err
+}
+
+// send makes one attempt of req.
+func (h *HTTPClient) send(ctx context.Context, req HTTPRequest, command string, body []byte, contentType string) (*HTTPResponse, error) {
+	svc := h.service
+	if svc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, svc.Timeout)
+		defer cancel()
+	}
+
+	httpReq, err := h.newRequest(ctx, req, body, contentType)
+	if err != nil {
+		return nil, resilience.Permanent(err)
+	}
+
+	h.log().Info(logger.NewHTTPRequest(svc.Name, command), map[string]any{
+		"method":  httpReq.Method,
+		"url":     httpReq.URL.String(),
+		"headers": flattenHeader(httpReq.Header),
+		"body":    loggedBody(body),
+	}, svc.Masks...)
+
+	start := time.Now()
+	resp, err := svc.Client.Do(httpReq)
+	resTime := time.Since(start).Milliseconds()
+	if err != nil {
+		h.log().SetSummary(logger.LogEventTag{
+			Node:        svc.Name,
+			Command:     command,
+			Code:        strconv.Itoa(http.StatusInternalServerError),
+			Description: err.Error(),
+			ResTime:     resTime,
+		}).Error(logger.NewHTTPResponse(svc.Name, command), map[string]any{
+			"error":    err.Error(),
+			"res_time": resTime,
+		}, svc.Masks...)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	resTime = time.Since(start).Milliseconds()
+	result := &HTTPResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}
+
+	description := ""
+	if err != nil {
+		description = err.Error()
+	}
+	h.log().SetSummary(logger.LogEventTag{
+		Node:        svc.Name,
+		Command:     command,
+		Code:        strconv.Itoa(resp.StatusCode),
+		Description: description,
+		ResTime:     resTime,
+	}).Info(logger.NewHTTPResponse(svc.Name, command), map[string]any{
+		"status":   resp.StatusCode,
+		"headers":  flattenHeader(resp.Header),
+		"body":     loggedBody(respBody),
+		"res_time": resTime,
+	}, svc.Masks...)
+
+	if err != nil {
+		return result, fmt.Errorf("read response of %s: %w", svc.Name, err)
+	}
+	return result, nil
+}
+
+// encodeBody returns the bytes sent for body and their content type.
+func (h *HTTPClient) encodeBody(body any) ([]byte, string, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, "", nil
+	case []byte:
+		return b, "", nil
+	case string:
+		return []byte(b), "", nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", fmt.Errorf("encode request to %s: %w", h.service.Name, err)
+	}
+	return data, "application/json", nil
+}
+
+func (h *HTTPClient) newRequest(ctx context.Context, req HTTPRequest, body []byte, contentType string) (*http.Request, error) {
+	target := req.Path
+	if !strings.Contains(target, "://") {
+		target = strings.TrimSuffix(h.service.BaseURL, "/") + "/" + strings.TrimPrefix(target, "/")
+	}
+	if len(req.Query) != 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + req.Query.Encode()
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range h.service.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	if id := h.ctx.TransactionId(); id != "" {
+		httpReq.Header.Set("X-Transaction-ID", id)
+	}
+	if id := h.ctx.SessionId(); id != "" {
+		httpReq.Header.Set("X-Session-ID", id)
+	}
+	return httpReq, nil
+}
+
+// statusError fails an attempt answered with a retryable status code.
+type statusError struct {
+	service string
+	code    int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.service, e.code)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// log returns the detail logger of the Context, or a discarding one.
+func (h *HTTPClient) log() logger.CustomLoggerService {
+	if h.ctx.detail == nil {
+		return discardLogger{}
+	}
+	return h.ctx.detail
+}
+
+// loggedBody returns body decoded when it is JSON, so it can be masked.
+func loggedBody(body []byte) any {
+	if len(body) == 0 {
+		return nil
+	}
+	var v any
+	if json.Unmarshal(body, &v) == nil {
+		return v
+	}
+	return string(body)
+}
+
+// flattenHeader returns the first value of every header of h for the detail
+// log, with credentials redacted.
+func flattenHeader(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for k := range h {
+		if credentialHeaders[http.CanonicalHeaderKey(k)] {
+			headers[k] = redacted
+			continue
+		}
+		headers[k] = h.Get(k)
+	}
+	return headers
+}
+
+// redacted replaces the value of credential headers in the detail log.
+const redacted = "[REDACTED]"
+
+// credentialHeaders are never written to the detail log, whatever the masks
+// of the service.
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// discardLogger is the detail logger of a Context without one.
+type discardLogger struct{}
+
+func (discardLogger) Init(logger.LogDto)                                         {}
+func (discardLogger) GetLogDto() logger.LogDto                                   { return logger.LogDto{} }
+func (discardLogger) Update(string, any)                                         {}
+func (discardLogger) Info(logger.LoggerAction, any, ...logger.MaskingOptionDto)  {}
+func (discardLogger) Debug(logger.LoggerAction, any, ...logger.MaskingOptionDto) {}
+func (discardLogger) Error(logger.LoggerAction, any, ...logger.MaskingOptionDto) {}
+func (discardLogger) Flush()                                                     {}
+func (discardLogger) End(int, string)                                            {}
+func (d discardLogger) SetSummary(logger.LogEventTag) logger.CustomLoggerService { return d }
+func (discardLogger) AddField(string, any)                                       {}
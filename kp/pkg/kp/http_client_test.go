@@ -0,0 +1,185 @@
+package kp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/resilience"
+)
+
+func TestHTTPClientPropagatesHeadersAndLogs(t *testing.T) {
+	var got *http.Request
+	var gotBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"42"}`))
+	}))
+	defer server.Close()
+
+	ctx, req, _, _, _, detail := CreateMockContextForTesting(t)
+	req.AddDataStr["TransactionId"] = "tx-1"
+	ctx.httpServices = newHTTPServices()
+	ctx.httpServices.add(HTTPService{
+		Name:    "orders",
+		BaseURL: server.URL + "/api/",
+		Headers: map[string]string{"X-Api-Key": "secret", "Authorization": "Bearer token"},
+	})
+
+	resp, err := ctx.HTTPClient("orders").Do(HTTPRequest{
+		Method:  http.MethodPost,
+		Path:    "/orders",
+		Query:   url.Values{"dry_run": {"true"}},
+		Body:    map[string]any{"sku": "A1"},
+		Command: "create_order",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.URL.Path != "/api/orders" || got.URL.Query().Get("dry_run") != "true" {
+		t.Errorf("unexpected url %s", got.URL)
+	}
+	if got.Header.Get("X-Transaction-ID") != "tx-1" || got.Header.Get("X-Session-ID") != "test-session" {
+		t.Errorf("expected the transaction and session headers, got %v", got.Header)
+	}
+	if got.Header.Get("X-Api-Key") != "secret" || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected the service headers and a JSON content type, got %v", got.Header)
+	}
+	if gotBody["sku"] != "A1" {
+		t.Errorf("expected the JSON body, got %v", gotBody)
+	}
+
+	var out struct{ ID string }
+	if err := resp.Bind(&out); err != nil || resp.StatusCode != http.StatusCreated || out.ID != "42" {
+		t.Errorf("unexpected response %d %q: %v", resp.StatusCode, resp.Body, err)
+	}
+
+	if len(detail.InfoCalls) != 2 {
+		t.Fatalf("expected the request and the response logged, got %d logs", len(detail.InfoCalls))
+	}
+	if want := logger.NewHTTPRequest("orders", "create_order"); detail.InfoCalls[0].Action != want {
+		t.Errorf("expected action %+v, got %+v", want, detail.InfoCalls[0].Action)
+	}
+	logged := detail.InfoCalls[0].Data.(map[string]any)["headers"].(map[string]string)
+	if logged["X-Api-Key"] != redacted || logged["Authorization"] != redacted {
+		t.Errorf("expected the credentials redacted in the detail log, got %v", logged)
+	}
+	if logged["X-Transaction-Id"] != "tx-1" {
+		t.Errorf("expected the other headers logged as is, got %v", logged)
+	}
+	if want := logger.NewHTTPResponse("orders", "create_order"); detail.InfoCalls[1].Action != want {
+		t.Errorf("expected action %+v, got %+v", want, detail.InfoCalls[1].Action)
+	}
+	if len(detail.SetSummaryCalls) != 1 {
+		t.Fatalf("expected one summary event, got %d", len(detail.SetSummaryCalls))
+	}
+	if s := detail.SetSummaryCalls[0]; s.Node != "orders" || s.Command != "create_order" || s.Code != "201" {
+		t.Errorf("unexpected summary event %+v", s)
+	}
+}
+
+func TestHTTPClientTransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	ctx, _, _, _, _, detail := CreateMockContextForTesting(t)
+	ctx.httpServices = newHTTPServices()
+	ctx.httpServices.add(HTTPService{Name: "slow", BaseURL: server.URL, Timeout: 10 * time.Millisecond})
+
+	if _, err := ctx.HTTPClient("slow").Get("/status", nil); err == nil {
+		t.Fatal("expected the timeout to be returned")
+	}
+	if len(detail.ErrorCalls) != 1 || detail.ErrorCalls[0].Action != logger.NewHTTPResponse("slow", "/status") {
+		t.Errorf("expected the failure logged as an error, got %+v", detail.ErrorCalls)
+	}
+	if len(detail.SetSummaryCalls) != 1 || detail.SetSummaryCalls[0].Code != "500" {
+		t.Errorf("expected a 500 summary event, got %+v", detail.SetSummaryCalls)
+	}
+}
+
+func TestHTTPClientUnknownService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	ctx, _, _, _, _, detail := CreateMockContextForTesting(t)
+	resp, err := ctx.HTTPClient("external").Get(server.URL+"/missing", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the status to be returned, got %d", resp.StatusCode)
+	}
+	if len(detail.SetSummaryCalls) != 1 || detail.SetSummaryCalls[0].Node != "external" || detail.SetSummaryCalls[0].Code != "404" {
+		t.Errorf("unexpected summary events %+v", detail.SetSummaryCalls)
+	}
+}
+
+func TestHTTPClientRetriesUnderResilience(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, _, _, _, _, detail := CreateMockContextForTesting(t)
+	ctx.httpServices = newHTTPServices()
+	ctx.httpServices.add(HTTPService{
+		Name:       "inventory",
+		BaseURL:    server.URL,
+		Resilience: &resilience.Config{Retry: resilience.RetryConfig{Attempts: 3}},
+	})
+
+	resp, err := ctx.HTTPClient("inventory").Post("/reserve", map[string]any{"sku": "A1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Errorf("expected 503 responses retried, got status %d after %d calls", resp.StatusCode, calls)
+	}
+	if len(detail.SetSummaryCalls) != 3 {
+		t.Errorf("expected every attempt in the summary, got %+v", detail.SetSummaryCalls)
+	}
+}
+
+func TestHTTPClientCircuitOpen(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, _, _, _, _, detail := CreateMockContextForTesting(t)
+	ctx.httpServices = newHTTPServices()
+	ctx.httpServices.add(HTTPService{
+		Name:       "inventory",
+		BaseURL:    server.URL,
+		Resilience: &resilience.Config{Breaker: resilience.BreakerConfig{FailureThreshold: 1}},
+	})
+	client := ctx.HTTPClient("inventory")
+
+	if resp, err := client.Get("/stock", nil); err != nil || resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the 502 response, got %v", err)
+	}
+	if _, err := client.Get("/stock", nil); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if last := detail.SetSummaryCalls[len(detail.SetSummaryCalls)-1]; last.Code != "503" {
+		t.Errorf("expected the rejected call in the summary as 503, got %+v", last)
+	}
+}
@@ -79,6 +79,11 @@ func newHTTPServer(conf *config.Config, tp *sdktrace.TracerProvider) *httpServer
 	return httpSrv
 }
 
+// defaultWriteTimeout is the time the server allows for writing a response.
+// Routes whose request timeout is longer, or disabled, extend the deadline of
+// their own request, see handler.extendWriteDeadline.
+const defaultWriteTimeout = 10 * time.Second
+
 func (s *httpServer) validateCertificateAndKeyFiles(certificateFile, keyFile string) bool {
 	if certificateFile == "" || keyFile == "" {
 		return false
@@ -104,7 +109,7 @@ func (s *httpServer) run() {
 	}
 
 	s.srv.ReadTimeout = 10 * time.Second
-	s.srv.WriteTimeout = 10 * time.Second
+	s.srv.WriteTimeout = defaultWriteTimeout
 	s.srv.MaxHeaderBytes = 1 << 20 // 1 MB
 
 	if s.validateCertificateAndKeyFiles(s.certFile, s.keyFile) {
@@ -160,16 +165,10 @@ func ShutdownWithContext(ctx context.Context, shutdownFunc func(ctx context.Cont
 
 const shutDownTimeout time.Duration = 30 * time.Second
 
-func getShutdownTimeoutFromConfig() (time.Duration, error) {
-	value := "30s"
-	if value == "" {
-		return shutDownTimeout, nil
-	}
-
-	timeout, err := time.ParseDuration(value)
-	if err != nil {
-		return shutDownTimeout, err
+func getShutdownTimeoutFromConfig(conf *config.Config) time.Duration {
+	if conf == nil || conf.Server.ShutdownTimeout <= 0 {
+		return shutDownTimeout
 	}
 
-	return timeout, nil
+	return conf.Server.ShutdownTimeout
 }
@@ -2,7 +2,6 @@ package kp
 
 import (
 	"context"
-	"runtime/debug"
 
 	config "github.com/sing3demons/go-common-kp/kp/configs"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
@@ -11,30 +10,41 @@ import (
 
 type KafkaClient struct {
 	kafkaClient    kafka.Client
-	subscriptions  map[string]SubscribeFunc
+	subscriptions  map[string]*subscription
+	patterns       []*patternSubscription
+	consumed       topicSet
 	log            LogService
 	maskingService logger.MaskingServiceInterface
 	conf           *config.Config
+	metrics        *appMetrics
+	httpServices   *httpServices
 }
 
 func newKafkaClient(kafkaClient kafka.Client, log LogService, conf *config.Config) *KafkaClient {
 	return &KafkaClient{
 		kafkaClient:    kafkaClient,
-		subscriptions:  make(map[string]SubscribeFunc),
+		subscriptions:  make(map[string]*subscription),
 		log:            log,
 		maskingService: log.maskingService,
 		conf:           conf,
 	}
 }
 
-func (kc *KafkaClient) startKafkaConsumer(ctx context.Context, topic string, handler SubscribeFunc) error {
+func (kc *KafkaClient) startKafkaConsumer(ctx context.Context, topic string, sub *subscription) error {
+	if sub.batch != nil {
+		return kc.startBatchConsumer(ctx, topic, sub)
+	}
+	if sub.concurrency > 1 {
+		return kc.startWorkerPool(ctx, topic, sub)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			kc.log.appLog.Logf("shutting down subscriber for topic %s", topic)
 			return nil
 		default:
-			err := kc.handleSubscription(ctx, topic, handler)
+			err := kc.handleSubscription(ctx, topic, sub)
 			if err != nil {
 				kc.log.appLog.Errorf("error in subscription for topic %s: %v", topic, err)
 			}
@@ -42,9 +52,13 @@ func (kc *KafkaClient) startKafkaConsumer(ctx context.Context, topic string, han
 	}
 }
 
-func (kc *KafkaClient) handleSubscription(ctx context.Context, topic string, handler SubscribeFunc) error {
+func (kc *KafkaClient) handleSubscription(ctx context.Context, topic string, sub *subscription) error {
 	msg, err := kc.kafkaClient.Subscribe(ctx, topic)
 	if err != nil {
+		if ctx.Err() != nil {
+			// the consumer is being stopped
+			return nil
+		}
 		kc.log.appLog.Errorf("error subscribing to topic %s: %v", topic, err)
 		return err
 	}
@@ -53,54 +67,68 @@ func (kc *KafkaClient) handleSubscription(ctx context.Context, topic string, han
 		return nil
 	}
 
-	msgCtx := newContext(nil, msg, kc.kafkaClient, kc.log, kc.conf)
-	err = func(ctx *Context) error {
-		defer func() {
-			panicRecovery(recover(), kc.log.appLog)
-		}()
+	kc.handleMessage(ctx, topic, msg, sub)
+	return nil
+}
 
-		return handler(ctx)
-	}(msgCtx)
+// handleMessage runs the handler for msg, retrying it as configured by the
+// subscription's retry policy, and commits msg once it has been handled or
+// forwarded to a retry or dead-letter topic. Messages are left uncommitted,
+// to be delivered again, when ctx is done before they are handled. The
+// consumer span of msg covers the handler runs, the forward and the commit.
+func (kc *KafkaClient) handleMessage(ctx context.Context, topic string, msg *kafka.Message, sub *subscription) {
+	var err error
+	defer func() { msg.EndSpan(err) }()
 
-	if err != nil {
-		kc.log.appLog.Errorf("error in handler for topic %s: %v", topic, err)
-		return nil
+	if ctx.Err() != nil || !waitRetryDelay(ctx, msg) {
+		return
 	}
 
-	if msg.Committer != nil {
-		msg.Commit()
+	err = kc.runHandler(topic, msg, sub.handler)
+	attempts := 1
+	for ; err != nil && attempts < sub.retry.attempts(); attempts++ {
+		if !sleep(ctx, sub.retry.backoff(attempts)) {
+			return
+		}
+		err = kc.runHandler(topic, msg, sub.handler)
 	}
 
-	return nil
-}
-
-func panicRecovery(re any, log logger.ILogger) {
-	if re == nil {
-		return
+	if err != nil {
+		if sub.retry == nil {
+			return
+		}
+		if err := kc.forwardFailed(ctx, msg, sub, attempts, err); err != nil {
+			kc.log.appLog.Errorf("error forwarding failed message of topic %s: %v", topic, err)
+			return
+		}
 	}
 
-	var e string
-	switch t := re.(type) {
-	case string:
-		e = t
-	case error:
-		e = t.Error()
-	default:
-		e = "Unknown panic type"
+	if msg.Committer != nil {
+		msg.Commit()
 	}
+}
 
-	// log.Error(panicLog{
-	// 	Error:      e,
-	// 	StackTrace: string(debug.Stack()),
-	// })
+func (kc *KafkaClient) runHandler(topic string, msg *kafka.Message, handler SubscribeFunc) error {
+	msgCtx := newContext(nil, msg, kc.kafkaClient, kc.log, kc.conf)
+	msgCtx.metrics = kc.metrics
+	msgCtx.httpServices = kc.httpServices
+	// Stopping the consumers must not cancel a handler that is already running:
+	// shutdown waits for it to finish and commit.
+	msgCtx.Context = context.WithoutCancel(msgCtx.Context)
+	err := func(ctx *Context) (err error) {
+		defer func() {
+			if re := recover(); re != nil {
+				err = recoverPanic(ctx, re, "kafka")
+			}
+		}()
 
-	log.Errorf("panic recovered: %v", panicLog{
-		Error:      e,
-		StackTrace: string(debug.Stack()),
-	})
-}
+		return handler(ctx)
+	}(msgCtx)
+	msgCtx.closeSummary(err)
+	kc.metrics.observeKafka(topic, err)
 
-type panicLog struct {
-	Error      string `json:"error,omitempty"`
-	StackTrace string `json:"stack_trace,omitempty"`
+	if err != nil {
+		kc.log.appLog.Errorf("error in handler for topic %s: %v", topic, err)
+	}
+	return err
 }
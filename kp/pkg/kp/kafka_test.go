@@ -0,0 +1,128 @@
+package kp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	segmentio "github.com/segmentio/kafka-go"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestKafkaClient(t *testing.T, client *MockKafkaClient) (*KafkaClient, LogService) {
+	t.Helper()
+
+	conf := &config.Config{}
+	conf.App.Name = "test-service"
+
+	log := LogService{
+		appLog:         &MockLoggerService{},
+		detailLog:      &MockLoggerService{},
+		summaryLog:     &MockLoggerService{},
+		maskingService: &MockMaskingService{},
+	}
+
+	return newKafkaClient(client, log, conf), log
+}
+
+func TestKafkaHandlerPanic(t *testing.T) {
+	kc, log := newTestKafkaClient(t, &MockKafkaClient{})
+
+	err := kc.handleSubscription(context.Background(), "orders", newSubscription("orders", func(c *Context) error {
+		panic("boom")
+	}))
+	if err != nil {
+		t.Fatalf("expected panic to be handled, got %v", err)
+	}
+
+	summaryLog := log.summaryLog.(*MockLoggerService)
+	if len(summaryLog.InfoCalls) != 1 {
+		t.Fatalf("expected 1 summary log, got %d", len(summaryLog.InfoCalls))
+	}
+	var summary logger.LogDto
+	if err := json.Unmarshal([]byte(summaryLog.InfoCalls[0]), &summary); err != nil {
+		t.Fatalf("invalid summary log: %v", err)
+	}
+	if summary.AppResultType != logger.SYSTEM_ERROR || summary.Severity != logger.CRITICAL_ISSUE {
+		t.Errorf("expected SYSTEM_ERROR/CRITICAL_ISSUE, got %s/%s", summary.AppResultType, summary.Severity)
+	}
+}
+
+func TestKafkaMessageMetadataLogged(t *testing.T) {
+	kc, log := newTestKafkaClient(t, &MockKafkaClient{})
+
+	msg := kafka.NewMessage(context.Background(), segmentio.Message{
+		Topic:     "orders",
+		Partition: 1,
+		Offset:    7,
+		Key:       []byte("customer-1"),
+		Value:     []byte(`{"id":1}`),
+	})
+	msg.ConsumerGroup = "billing"
+
+	kc.handleMessage(context.Background(), "orders", msg, newSubscription("orders", func(c *Context) error {
+		if c.Param("partition") != "1" || c.Param("offset") != "7" {
+			t.Errorf("expected partition and offset through the request, got %s/%s", c.Param("partition"), c.Param("offset"))
+		}
+		return nil
+	}))
+
+	summaryLog := log.summaryLog.(*MockLoggerService)
+	if len(summaryLog.InfoCalls) != 1 {
+		t.Fatalf("expected 1 summary log, got %d", len(summaryLog.InfoCalls))
+	}
+	var summary logger.LogDto
+	if err := json.Unmarshal([]byte(summaryLog.InfoCalls[0]), &summary); err != nil {
+		t.Fatalf("invalid summary log: %v", err)
+	}
+	if summary.Metadata.Topic != "orders" || summary.Metadata.Key != "customer-1" || summary.Metadata.ConsumerGroup != "billing" {
+		t.Errorf("unexpected metadata %+v", summary.Metadata)
+	}
+}
+
+func TestKafkaConsumerSpanCoversHandler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prevTP) })
+
+	broker := kafka.NewMemoryBroker()
+	if err := broker.Client("").Publish(context.Background(), "orders", []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("unexpected publish error: %v", err)
+	}
+
+	kc, _ := newTestKafkaClient(t, &MockKafkaClient{})
+	kc.kafkaClient = broker.Client("billing")
+
+	recording := false
+	err := kc.handleSubscription(context.Background(), "orders", newSubscription("orders", func(c *Context) error {
+		recording = trace.SpanFromContext(c).IsRecording()
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !recording {
+		t.Error("expected the handler to run inside the open consumer span")
+	}
+	if broker.CommittedOffset("billing", "orders", 0) != 1 {
+		t.Error("expected the message to be committed")
+	}
+	var receive sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == "orders receive" {
+			receive = s
+		}
+	}
+	if receive == nil {
+		t.Fatal("expected the consumer span to be ended once the message is handled")
+	}
+}
@@ -0,0 +1,59 @@
+package kp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+func TestAppWithMemoryKafka(t *testing.T) {
+	conf := &config.Config{}
+	conf.App.Name = "test-service"
+
+	broker := kafka.NewMemoryBroker()
+	app := NewApplication(conf, WithKafkaClient(broker.Client("billing"))).(*App)
+	app.StartKafka() // keeps the memory client
+
+	err := app.Consumer("orders", func(c *Context) error {
+		var order struct {
+			ID string `json:"id"`
+		}
+		if err := c.Bind(&order); err != nil {
+			return err
+		}
+		return c.Publish(c, "invoices", []byte(`{"order":"`+order.ID+`"}`))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = app.startConsumer(ctx)
+	}()
+
+	producer := broker.Client("")
+	if err := producer.Publish(context.Background(), "orders", []byte(`{"id":"o-1"}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for broker.CommittedOffset("billing", "orders", 0) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	invoices := broker.Messages("invoices")
+	if len(invoices) != 1 || string(invoices[0].Value) != `{"order":"o-1"}` {
+		t.Fatalf("expected one invoice, got %v", invoices)
+	}
+	if got := broker.CommittedOffset("billing", "orders", 0); got != 1 {
+		t.Errorf("expected the order to be committed, got offset %d", got)
+	}
+}
@@ -0,0 +1,116 @@
+package kp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+	"github.com/sing3demons/go-common-kp/kp/pkg/metrics"
+)
+
+const defaultMetricsPath = "/metrics"
+
+// statusClientClosedRequest is recorded when the client goes away before a response is sent.
+const statusClientClosedRequest = 499
+
+// appMetrics holds the registry of an App and the metrics built into the framework.
+type appMetrics struct {
+	registry *metrics.Registry
+
+	httpRequests *metrics.Counter
+	httpDuration *metrics.Histogram
+	panics       *metrics.Counter
+	kafkaHandled *metrics.Counter
+
+	kafkaConsumed      *metrics.Counter
+	kafkaConsumeErrors *metrics.Counter
+	kafkaLag           *metrics.Gauge
+	kafkaPublished     *metrics.Counter
+	kafkaPublishWrites *metrics.Counter
+	kafkaPublishErrors *metrics.Counter
+}
+
+func newAppMetrics() *appMetrics {
+	r := metrics.NewRegistry()
+
+	return &appMetrics{
+		registry: r,
+
+		httpRequests: r.Counter("http_requests_total", "Number of HTTP requests by route pattern and status.", "method", "route", "status"),
+		httpDuration: r.Histogram("http_request_duration_seconds", "Duration of HTTP requests by route pattern and status.", nil, "method", "route", "status"),
+		panics:       r.Counter("kp_panics_recovered_total", "Number of panics recovered from handlers.", "source"),
+		kafkaHandled: r.Counter("kafka_consumer_handled_total", "Number of Kafka messages handled by result.", "topic", "result"),
+
+		kafkaConsumed:      r.Counter("kafka_consumer_messages_total", "Number of Kafka messages fetched per topic.", "topic"),
+		kafkaConsumeErrors: r.Counter("kafka_consumer_errors_total", "Number of Kafka fetch errors per topic.", "topic"),
+		kafkaLag:           r.Gauge("kafka_consumer_lag", "Consumer lag per topic as reported by the reader.", "topic"),
+		kafkaPublished:     r.Counter("kafka_producer_messages_total", "Number of Kafka messages published per topic.", "topic"),
+		kafkaPublishWrites: r.Counter("kafka_producer_writes_total", "Number of Kafka write requests."),
+		kafkaPublishErrors: r.Counter("kafka_producer_errors_total", "Number of failed Kafka write requests."),
+	}
+}
+
+// observeHTTP records a served request under its route pattern, so paths with
+// ids do not explode the number of series.
+func (m *appMetrics) observeHTTP(r *http.Request, status int, start time.Time) {
+	if m == nil {
+		return
+	}
+
+	route := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			route = tpl
+		}
+	}
+
+	code := strconv.Itoa(status)
+	m.httpRequests.Inc(r.Method, route, code)
+	m.httpDuration.Observe(time.Since(start).Seconds(), r.Method, route, code)
+}
+
+func (m *appMetrics) observeKafka(topic string, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	m.kafkaHandled.Inc(topic, result)
+}
+
+// collectKafka folds the client statistics into the registry before each scrape.
+func (m *appMetrics) collectKafka(client kafka.Client) {
+	provider, ok := client.(kafka.StatsProvider)
+	if !ok {
+		return
+	}
+
+	stats := provider.Stats()
+	for topic, rs := range stats.Readers {
+		m.kafkaConsumed.Add(float64(rs.Messages), topic)
+		m.kafkaConsumeErrors.Add(float64(rs.Errors), topic)
+		m.kafkaLag.Set(float64(rs.Lag), topic)
+	}
+	for topic, n := range stats.Published {
+		m.kafkaPublished.Add(float64(n), topic)
+	}
+	m.kafkaPublishWrites.Add(float64(stats.Writer.Writes))
+	m.kafkaPublishErrors.Add(float64(stats.Writer.Errors))
+}
+
+// fallbackRegistry backs Context.Metrics for contexts not created by an App.
+var fallbackRegistry = metrics.NewRegistry()
+
+// Metrics returns the metrics registry of the application, for handlers to
+// record business metrics exposed on the metrics endpoint.
+func (c *Context) Metrics() *metrics.Registry {
+	if c.metrics == nil {
+		return fallbackRegistry
+	}
+	return c.metrics.registry
+}
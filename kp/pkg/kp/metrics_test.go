@@ -0,0 +1,42 @@
+package kp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	app := newTestApp(t)
+	app.Get("/orders/{id}", func(c *Context) error {
+		c.Metrics().Counter("orders_viewed_total", "Orders viewed.").Inc()
+		return c.JSON(http.StatusOK, "ok")
+	})
+	app.Get("/missing/{id}", func(c *Context) error {
+		return ErrNotFound("", "")
+	})
+
+	serve(app, http.MethodGet, "/orders/1")
+	serve(app, http.MethodGet, "/orders/2")
+	serve(app, http.MethodGet, "/missing/3")
+
+	rec := serve(app, http.MethodGet, "/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/orders/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/missing/{id}",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/orders/{id}",status="200"} 2`,
+		`orders_viewed_total 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package kp
+
+// Middleware wraps a Handler with cross-cutting logic such as authentication,
+// tenant resolution or auditing. A middleware runs with the full *Context, so it
+// can use the detail/summary loggers, and it can abort the request by writing a
+// response (e.g. c.JSON) or returning an error without calling next.
+type Middleware func(next Handler) Handler
+
+// chainMiddleware wraps h so that mws run in the order they are given.
+func chainMiddleware(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] != nil {
+			h = mws[i](h)
+		}
+	}
+	return h
+}
+
+// stackMiddlewares returns a resolver for parent's middlewares followed by mws.
+// The parent is resolved on every call so middlewares added later with Use are
+// picked up by routes that were already registered.
+func stackMiddlewares(parent func() []Middleware, mws []Middleware) func() []Middleware {
+	return func() []Middleware {
+		inherited := parent()
+		stack := make([]Middleware, 0, len(inherited)+len(mws))
+		stack = append(stack, inherited...)
+		return append(stack, mws...)
+	}
+}
@@ -0,0 +1,124 @@
+package kp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+)
+
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+
+	conf := &config.Config{}
+	conf.App.Name = "test-service"
+	conf.App.Version = "1.0.0"
+
+	app := &App{
+		conf:           conf,
+		httpServer:     newHTTPServer(conf, nil),
+		AppLog:         &MockLoggerService{},
+		DetailLog:      &MockLoggerService{},
+		SummaryLog:     &MockLoggerService{},
+		maskingService: &MockMaskingService{},
+		metrics:        newAppMetrics(),
+		health:         newHealth(),
+		httpServices:   newHTTPServices(),
+	}
+	app.registerMetricsEndpoint()
+	app.registerHealthEndpoints()
+
+	return app
+}
+
+func serve(app *App, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.httpServer.router.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	app := newTestApp(t)
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(c *Context) error {
+				calls = append(calls, name)
+				return next(c)
+			}
+		}
+	}
+
+	app.Get("/orders", func(c *Context) error {
+		calls = append(calls, "handler")
+		return c.JSON(http.StatusOK, "ok")
+	}, record("route"))
+	// Use after registration still applies to the route.
+	app.Use(record("app-1"), record("app-2"))
+
+	rec := serve(app, http.MethodGet, "/orders")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got, want := strings.Join(calls, ","), "app-1,app-2,route,handler"; got != want {
+		t.Errorf("expected call order %q, got %q", want, got)
+	}
+}
+
+func TestMiddlewareAbort(t *testing.T) {
+	app := newTestApp(t)
+
+	auth := func(next Handler) Handler {
+		return func(c *Context) error {
+			if c.Request.Header("Authorization") == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+			}
+			return next(c)
+		}
+	}
+
+	app.Get("/secure", func(c *Context) error {
+		return errors.New("handler must not run")
+	}, auth)
+
+	rec := serve(app, http.MethodGet, "/secure")
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "unauthorized") {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestUseWhileServing(t *testing.T) {
+	app := newTestApp(t)
+	group := app.Group("/api")
+	noop := func(next Handler) Handler { return next }
+
+	app.Get("/orders", func(c *Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+	group.Get("/users", func(c *Context) error {
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 50 {
+			app.Use(noop)
+			group.Use(noop)
+		}
+	}()
+	for range 50 {
+		serve(app, http.MethodGet, "/orders")
+		serve(app, http.MethodGet, "/api/users")
+	}
+	<-done
+}
@@ -0,0 +1,78 @@
+package kp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+var errKafkaNotConfigured = errors.New("kafka client is not configured")
+
+// Publish publishes message to topic with the headers added by PublishMessage.
+func (c *Context) Publish(ctx context.Context, topic string, message []byte) error {
+	return c.PublishMessage(ctx, kafka.OutgoingMessage{Topic: topic, Value: message})
+}
+
+// PublishMessage publishes msg with the transaction, session and request IDs of
+// the context added to its headers; the Kafka client adds the trace context of
+// ctx. Headers already set on msg are kept.
+func (c *Context) PublishMessage(ctx context.Context, msg kafka.OutgoingMessage) error {
+	if c.Client == nil {
+		return errKafkaNotConfigured
+	}
+	if ctx == nil {
+		ctx = c.Context
+	}
+
+	return c.Client.PublishMessage(ctx, c.withHeaders(msg))
+}
+
+// Transaction runs kafka.Client.Transaction with the headers of PublishMessage
+// added to the messages published by fn.
+func (c *Context) Transaction(ctx context.Context, fn func(tx kafka.Tx) error) error {
+	if c.Client == nil {
+		return errKafkaNotConfigured
+	}
+	if ctx == nil {
+		ctx = c.Context
+	}
+
+	return c.Client.Transaction(ctx, func(tx kafka.Tx) error {
+		return fn(&contextTx{Tx: tx, c: c})
+	})
+}
+
+type contextTx struct {
+	kafka.Tx
+	c *Context
+}
+
+func (tx *contextTx) Publish(topic string, message []byte) {
+	tx.PublishMessage(kafka.OutgoingMessage{Topic: topic, Value: message})
+}
+
+func (tx *contextTx) PublishMessage(msg kafka.OutgoingMessage) {
+	tx.Tx.PublishMessage(tx.c.withHeaders(msg))
+}
+
+// withHeaders returns msg with the transaction, session and request IDs of the
+// context added to the headers it does not set.
+func (c *Context) withHeaders(msg kafka.OutgoingMessage) kafka.OutgoingMessage {
+	headers := make(map[string]string, len(msg.Headers)+3)
+	setHeader := func(key, value string) {
+		if value != "" {
+			headers[key] = value
+		}
+	}
+	setHeader(kafka.HeaderTransactionID, c.TransactionId())
+	setHeader(kafka.HeaderSessionID, c.SessionId())
+	setHeader(kafka.HeaderRequestID, c.RequestId())
+
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	msg.Headers = headers
+
+	return msg
+}
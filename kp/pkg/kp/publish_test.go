@@ -0,0 +1,114 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextPublishInjectsHeaders(t *testing.T) {
+	ctx, _, _, mockKafka, _, _ := CreateMockContextForTesting(t)
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	parent := trace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	err := ctx.PublishMessage(parent, kafka.OutgoingMessage{
+		Topic:   "orders",
+		Key:     []byte("customer-1"),
+		Value:   []byte(`{"id":1}`),
+		Headers: map[string]string{"x-source": "test", kafka.HeaderSessionID: "own-session"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockKafka.PublishCalls) != 1 {
+		t.Fatalf("expected 1 publish, got %d", len(mockKafka.PublishCalls))
+	}
+
+	call := mockKafka.PublishCalls[0]
+	if string(call.Key) != "customer-1" || call.Headers["x-source"] != "test" {
+		t.Errorf("expected key and headers to be kept, got %s %v", call.Key, call.Headers)
+	}
+	if call.Headers[kafka.HeaderSessionID] != "own-session" {
+		t.Errorf("expected headers of the message to win, got %v", call.Headers)
+	}
+	if call.Headers[kafka.HeaderRequestID] != "test-request" {
+		t.Errorf("expected request id of the context, got %v", call.Headers)
+	}
+
+	if err := ctx.Publish(parent, "orders", []byte("plain")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if call := mockKafka.PublishCalls[1]; call.Headers[kafka.HeaderRequestID] != "test-request" {
+		t.Errorf("expected Publish to add the request id, got %v", call.Headers)
+	}
+}
+
+func TestContextPublishPropagatesTraceContext(t *testing.T) {
+	prevProp := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prevProp) })
+
+	ctx, _, _, _, _, _ := CreateMockContextForTesting(t)
+	broker := kafka.NewMemoryBroker()
+	ctx.Client = broker.Client("")
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	parent := trace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	if err := ctx.Publish(parent, "orders", []byte("plain")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	messages := broker.Messages("orders")
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(messages))
+	}
+	if traceparent := messages[0].Header("traceparent"); !strings.HasPrefix(traceparent, "00-"+spanCtx.TraceID().String()+"-") {
+		t.Errorf("expected the traceparent of trace %s, got %q", spanCtx.TraceID(), traceparent)
+	}
+	if messages[0].Header(kafka.HeaderRequestID) != "test-request" {
+		t.Errorf("expected the request id of the context, got %v", messages[0].Headers())
+	}
+}
+
+func TestContextTransaction(t *testing.T) {
+	ctx, _, _, mockKafka, _, _ := CreateMockContextForTesting(t)
+
+	err := ctx.Transaction(ctx, func(tx kafka.Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		return errors.New("insufficient funds")
+	})
+	if err == nil || len(mockKafka.PublishCalls) != 0 {
+		t.Fatalf("expected an aborted transaction, got %v and %d publishes", err, len(mockKafka.PublishCalls))
+	}
+
+	err = ctx.Transaction(ctx, func(tx kafka.Tx) error {
+		tx.Publish("ledger", []byte(`{"debit":10}`))
+		tx.Publish("audit", []byte(`{"debit":10}`))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockKafka.PublishCalls) != 2 {
+		t.Fatalf("expected 2 publishes, got %d", len(mockKafka.PublishCalls))
+	}
+	if got := mockKafka.PublishCalls[0].Headers[kafka.HeaderRequestID]; got != "test-request" {
+		t.Errorf("expected transaction messages to carry the request id, got %q", got)
+	}
+}
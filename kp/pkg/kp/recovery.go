@@ -0,0 +1,40 @@
+package kp
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+)
+
+type panicLog struct {
+	Error      string `json:"error,omitempty"`
+	StackTrace string `json:"stack_trace,omitempty"`
+}
+
+// recoverPanic converts a value recovered from a handler panic into a 500
+// SYSTEM_ERROR with CRITICAL_ISSUE severity, after writing an EXCEPTION detail
+// log with the stack trace. It must be called from the deferred function of the
+// panicking goroutine so the stack trace points at the panic.
+func recoverPanic(c *Context, re any, source string) *Error {
+	if c.metrics != nil {
+		c.metrics.panics.Inc(source)
+	}
+
+	pl := panicLog{
+		Error:      fmt.Sprint(re),
+		StackTrace: string(debug.Stack()),
+	}
+
+	if c.detail != nil {
+		c.detail.Error(logger.NewException(c.URL(), source), pl)
+	}
+	if c.appLog != nil {
+		c.appLog.Errorf("panic recovered: %v", pl.Error)
+	}
+
+	return NewError(http.StatusInternalServerError, "", "").
+		WithCause(fmt.Errorf("panic: %s", pl.Error)).
+		WithSeverity(logger.CRITICAL_ISSUE)
+}
@@ -0,0 +1,417 @@
+package kp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLoggedBodySize caps the bodies of Blob and Stream responses in the detail log.
+const maxLoggedBodySize = 1024
+
+const (
+	MIMEApplicationJSON = "application/json; charset=utf-8"
+	MIMEApplicationXML  = "application/xml; charset=utf-8"
+	MIMETextPlain       = "text/plain; charset=utf-8"
+	MIMEEventStream     = "text/event-stream"
+)
+
+// XML sends v encoded as XML.
+func (c *Context) XML(code int, v any) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return ErrInternal(fmt.Errorf("encode xml response: %w", err))
+	}
+
+	return c.respond(code, MIMEApplicationXML, v, func(w io.Writer) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// String sends s as plain text.
+func (c *Context) String(code int, s string) error {
+	return c.respond(code, MIMETextPlain, s, func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	})
+}
+
+// Blob sends data with the given content type. The detail log gets at most
+// the first kilobyte of it.
+func (c *Context) Blob(code int, contentType string, data []byte) error {
+	return c.respond(code, contentType, loggedBlob(contentType, data, len(data)), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// NoContent sends code without a body, e.g. http.StatusNoContent.
+func (c *Context) NoContent(code int) error {
+	return c.respond(code, "", nil, nil)
+}
+
+// Redirect redirects the client to url with a 3xx code.
+func (c *Context) Redirect(code int, url string) error {
+	if code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect {
+		return ErrInternal(fmt.Errorf("invalid redirect status %d", code))
+	}
+
+	if c.ResponseWriter != nil {
+		c.ResponseWriter.Header().Set("Location", url)
+	}
+	return c.respond(code, "", map[string]any{"location": url}, nil)
+}
+
+// File sends the file at path. Range, If-Modified-Since and the other
+// conditional requests are served as by http.ServeContent, answering 206,
+// 304 or 416 when they apply. A missing file is a 404 error.
+func (c *Context) File(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound("", "file not found").WithCause(err)
+		}
+		return ErrInternal(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return ErrInternal(err)
+	}
+	if info.IsDir() {
+		return ErrNotFound("", "file not found").WithCause(fmt.Errorf("%s is a directory", path))
+	}
+
+	if c.ResponseWriter == nil {
+		return nil
+	}
+	if !c.claimResponse() {
+		return errResponseAlreadySent
+	}
+
+	w := &statusWriter{ResponseWriter: c.ResponseWriter}
+	http.ServeContent(w, c.rawRequest(), info.Name(), info.ModTime(), f)
+
+	c.logResponse(w.statusCode(), map[string]any{
+		"file":         filepath.Base(path),
+		"size":         info.Size(),
+		"contentType":  w.Header().Get("Content-Type"),
+		"contentRange": w.Header().Get("Content-Range"),
+		"written":      w.written,
+	}, "", nil)
+	return nil
+}
+
+// Stream sends a chunked response of contentType. step is called, and what
+// it wrote flushed to the client, until it returns false or the request is
+// done. The detail log gets the size of the stream and its first kilobyte.
+//
+// Streams outlive the request timeout only on routes whose Timeout is
+// disabled. Use MIMEEventStream and SSEvent for server-sent events.
+func (c *Context) Stream(code int, contentType string, step func(w io.Writer) bool) error {
+	if c.ResponseWriter == nil {
+		return nil
+	}
+	if !c.claimResponse() {
+		return errResponseAlreadySent
+	}
+
+	header := c.ResponseWriter.Header()
+	header.Set("Content-Type", contentType)
+	if strings.HasPrefix(contentType, MIMEEventStream) {
+		header.Set("Cache-Control", "no-cache")
+	}
+	c.ResponseWriter.WriteHeader(code)
+
+	rc := http.NewResponseController(c.ResponseWriter)
+	w := &capturingWriter{w: c.ResponseWriter}
+	var err error
+	for c.Err() == nil {
+		more := step(w)
+		if err = rc.Flush(); err != nil || w.err != nil || !more {
+			break
+		}
+	}
+	if w.err != nil {
+		err = w.err
+	}
+
+	c.logResponse(code, loggedBlob(contentType, w.head, w.size), "", err)
+	return nil
+}
+
+// SSEvent is a server-sent event written to a Stream of MIMEEventStream.
+type SSEvent struct {
+	ID    string
+	Event string
+	// Data is sent as is when it is a string or a []byte, as JSON otherwise.
+	Data  any
+	Retry time.Duration
+}
+
+// WriteTo writes the event in the text/event-stream format.
+func (e SSEvent) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry.Milliseconds())
+	}
+
+	var data string
+	switch d := e.Data.(type) {
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		encoded, err := json.Marshal(d)
+		if err != nil {
+			return 0, err
+		}
+		data = string(encoded)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Negotiate sends v in the format the Accept header prefers among JSON, XML
+// (but for maps, which encoding/xml cannot encode) and, for strings and
+// fmt.Stringers, plain text. JSON is sent when the client accepts anything; a
+// 406 error is returned when it accepts none.
+func (c *Context) Negotiate(code int, v any) error {
+	offers := []string{"application/json"}
+	if rv := reflect.Indirect(reflect.ValueOf(v)); rv.Kind() != reflect.Map {
+		offers = append(offers, "application/xml", "text/xml")
+	}
+	if isText(v) {
+		offers = append(offers, "text/plain")
+	}
+
+	switch c.Accepts(offers...) {
+	case "application/json":
+		return c.JSON(code, v)
+	case "application/xml", "text/xml":
+		return c.XML(code, v)
+	case "text/plain":
+		return c.String(code, fmt.Sprint(v))
+	}
+	return NewError(http.StatusNotAcceptable, "", "")
+}
+
+// Accepts returns the offered media type the Accept header of the request
+// prefers, the first offer when the header is missing, and "" when none is
+// acceptable.
+func (c *Context) Accepts(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	accept := c.Request.Header("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ, sub string
+		q        float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		typ, sub, _ := strings.Cut(mediaType, "/")
+		ranges = append(ranges, mediaRange{typ: typ, sub: sub, q: q})
+	}
+	// the most specific range matching an offer decides its quality
+	specificity := func(r mediaRange) int {
+		switch {
+		case r.typ == "*":
+			return 0
+		case r.sub == "*":
+			return 1
+		}
+		return 2
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		typ, sub, _ := strings.Cut(offer, "/")
+		q, spec := 0.0, -1
+		for _, r := range ranges {
+			if (r.typ == "*" || r.typ == typ) && (r.sub == "*" || r.sub == sub) && specificity(r) > spec {
+				q, spec = r.q, specificity(r)
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// respond sends the status, the content type and what write writes, then
+// closes the summary log with logged as the outbound payload.
+func (c *Context) respond(code int, contentType string, logged any, write func(w io.Writer) error) error {
+	if c.ResponseWriter == nil {
+		return nil
+	}
+	if !c.claimResponse() {
+		return errResponseAlreadySent
+	}
+
+	if contentType != "" {
+		c.ResponseWriter.Header().Set("Content-Type", contentType)
+	}
+	c.ResponseWriter.WriteHeader(code)
+
+	var err error
+	if write != nil {
+		err = write(c.ResponseWriter)
+	}
+	c.logResponse(code, logged, "", err)
+
+	return nil
+}
+
+// rawRequest returns the request being served, or one rebuilt from the
+// Request of the Context.
+func (c *Context) rawRequest() *http.Request {
+	if c.httpRequest != nil {
+		return c.httpRequest
+	}
+
+	r := &http.Request{Method: c.Method(), Header: make(http.Header)}
+	for key, value := range c.Headers() {
+		r.Header.Set(key, value)
+	}
+	return r.WithContext(c)
+}
+
+// loggedBlob describes a body of size bytes starting with head for the
+// detail log: text as is, binary base64 encoded, both capped.
+func loggedBlob(contentType string, head []byte, size int) map[string]any {
+	logged := map[string]any{"contentType": contentType, "size": size}
+	if len(head) > maxLoggedBodySize {
+		head = head[:maxLoggedBodySize]
+	}
+	if size > len(head) {
+		logged["truncated"] = true
+	}
+
+	if isTextContentType(contentType) {
+		logged["body"] = strings.ToValidUTF8(string(head), "")
+	} else {
+		logged["body"] = base64.StdEncoding.EncodeToString(head)
+	}
+	return logged
+}
+
+func isTextContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/javascript",
+		mediaType == "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
+func isText(v any) bool {
+	switch v.(type) {
+	case string, fmt.Stringer:
+		return true
+	}
+	return false
+}
+
+// statusWriter records the status sent through it and the bytes written.
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// capturingWriter counts the bytes of a stream and keeps its first kilobyte.
+type capturingWriter struct {
+	w    io.Writer
+	head []byte
+	size int
+	err  error
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n, err := w.w.Write(b)
+	if room := maxLoggedBodySize - len(w.head); room > 0 {
+		w.head = append(w.head, b[:min(room, n)]...)
+	}
+	w.size += n
+	w.err = err
+	return n, err
+}
@@ -0,0 +1,228 @@
+package kp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriters(t *testing.T) {
+	type order struct {
+		ID string `json:"id" xml:"id"`
+	}
+
+	tests := []struct {
+		name        string
+		respond     func(c *Context) error
+		status      int
+		contentType string
+		body        string
+		header      string
+	}{
+		{
+			name:        "xml",
+			respond:     func(c *Context) error { return c.XML(http.StatusOK, order{ID: "42"}) },
+			status:      http.StatusOK,
+			contentType: MIMEApplicationXML,
+			body:        `<?xml version="1.0" encoding="UTF-8"?>` + "\n<order><id>42</id></order>",
+		},
+		{
+			name:        "string",
+			respond:     func(c *Context) error { return c.String(http.StatusAccepted, "queued") },
+			status:      http.StatusAccepted,
+			contentType: MIMETextPlain,
+			body:        "queued",
+		},
+		{
+			name:        "blob",
+			respond:     func(c *Context) error { return c.Blob(http.StatusOK, "image/png", []byte{0x89, 'P', 'N', 'G'}) },
+			status:      http.StatusOK,
+			contentType: "image/png",
+			body:        "\x89PNG",
+		},
+		{
+			name:    "no content",
+			respond: func(c *Context) error { return c.NoContent(http.StatusNoContent) },
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "redirect",
+			respond: func(c *Context) error { return c.Redirect(http.StatusFound, "/orders/42") },
+			status:  http.StatusFound,
+			header:  "/orders/42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			var second error
+			app.Get("/orders", func(c *Context) error {
+				if err := tt.respond(c); err != nil {
+					return err
+				}
+				second = tt.respond(c)
+				return nil
+			})
+
+			rec := serve(app, http.MethodGet, "/orders")
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, got)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, rec.Body.String())
+			}
+			if got := rec.Header().Get("Location"); got != tt.header {
+				t.Errorf("expected location %q, got %q", tt.header, got)
+			}
+			if second != errResponseAlreadySent {
+				t.Errorf("expected the second response to be rejected, got %v", second)
+			}
+			if calls := app.SummaryLog.(*MockLoggerService).InfoCalls; len(calls) != 1 {
+				t.Errorf("expected 1 summary log, got %d", len(calls))
+			}
+		})
+	}
+}
+
+func TestFileRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("0123456789"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp(t)
+	app.Get("/report", func(c *Context) error { return c.File(path) })
+	app.Get("/missing", func(c *Context) error { return c.File(path + ".gone") })
+
+	req := httptest.NewRequest(http.MethodGet, "/report", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	app.httpServer.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "2345" {
+		t.Fatalf("expected 206 with bytes 2-5, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("unexpected content range %q", got)
+	}
+	if calls := app.SummaryLog.(*MockLoggerService).InfoCalls; len(calls) != 1 || !strings.Contains(calls[0], "206") {
+		t.Errorf("expected one summary log with status 206, got %v", calls)
+	}
+
+	if rec := serve(app, http.MethodGet, "/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing file, got %d", rec.Code)
+	}
+}
+
+func TestStreamServerSentEvents(t *testing.T) {
+	app := newTestApp(t)
+	app.Get("/events", func(c *Context) error {
+		n := 0
+		return c.Stream(http.StatusOK, MIMEEventStream, func(w io.Writer) bool {
+			n++
+			if _, err := (SSEvent{ID: "1", Event: "tick", Data: map[string]int{"n": n}}).WriteTo(w); err != nil {
+				return false
+			}
+			return n < 3
+		})
+	})
+
+	rec := serve(app, http.MethodGet, "/events")
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != MIMEEventStream {
+		t.Fatalf("unexpected status %d and content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !rec.Flushed {
+		t.Error("expected the events to be flushed")
+	}
+	want := "id: 1\nevent: tick\ndata: {\"n\":1}\n\n"
+	if !strings.HasPrefix(rec.Body.String(), want) || strings.Count(rec.Body.String(), "event: tick") != 3 {
+		t.Errorf("unexpected stream %q", rec.Body.String())
+	}
+	if calls := app.SummaryLog.(*MockLoggerService).InfoCalls; len(calls) != 1 {
+		t.Errorf("expected 1 summary log, got %d", len(calls))
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	type order struct {
+		ID string `json:"id" xml:"id"`
+	}
+
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{accept: "", status: http.StatusOK, contentType: MIMEApplicationJSON},
+		{accept: "*/*", status: http.StatusOK, contentType: MIMEApplicationJSON},
+		{accept: "application/xml", status: http.StatusOK, contentType: MIMEApplicationXML},
+		{accept: "application/json;q=0.5, text/*", status: http.StatusOK, contentType: MIMEApplicationXML},
+		{accept: "text/html", status: http.StatusNotAcceptable, contentType: MIMEApplicationJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			app := newTestApp(t)
+			app.Get("/orders", func(c *Context) error {
+				return c.Negotiate(http.StatusOK, order{ID: "42"})
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			app.httpServer.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, got)
+			}
+		})
+	}
+
+	t.Run("map", func(t *testing.T) {
+		app := newTestApp(t)
+		app.Get("/orders", func(c *Context) error {
+			return c.Negotiate(http.StatusOK, map[string]string{"id": "42"})
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("Accept", "application/xml, application/json;q=0.1")
+		rec := httptest.NewRecorder()
+		app.httpServer.router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != MIMEApplicationJSON {
+			t.Errorf("expected maps sent as JSON, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+	})
+}
+
+func TestLoggedBlobIsCapped(t *testing.T) {
+	data := bytes.Repeat([]byte{0xff}, 3*maxLoggedBodySize)
+
+	logged := loggedBlob("application/octet-stream", data, len(data))
+	if logged["size"] != len(data) || logged["truncated"] != true {
+		t.Errorf("expected the full size and a truncation mark, got %v", logged)
+	}
+	body, err := base64.StdEncoding.DecodeString(logged["body"].(string))
+	if err != nil || len(body) != maxLoggedBodySize {
+		t.Errorf("expected %d bytes logged, got %d: %v", maxLoggedBodySize, len(body), err)
+	}
+
+	if logged := loggedBlob("text/csv", []byte("a,b"), 3); logged["body"] != "a,b" || logged["truncated"] != nil {
+		t.Errorf("expected short text logged as is, got %v", logged)
+	}
+}
@@ -0,0 +1,127 @@
+package kp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// guardedResponseWriter serializes writes to the underlying ResponseWriter and
+// drops them once the request has been expired by the framework, so a handler
+// still running after its deadline cannot write into a response that was
+// already sent (or into a ResponseWriter the server has reclaimed).
+type guardedResponseWriter struct {
+	http.ResponseWriter
+
+	mu          sync.Mutex
+	expired     bool
+	wroteHeader bool
+	status      int
+	header      http.Header
+}
+
+func newGuardedResponseWriter(w http.ResponseWriter) *guardedResponseWriter {
+	return &guardedResponseWriter{ResponseWriter: w}
+}
+
+func (w *guardedResponseWriter) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired {
+		// hand late writers a detached map so they cannot touch the sent headers
+		if w.header == nil {
+			w.header = make(http.Header)
+		}
+		return w.header
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *guardedResponseWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired || w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *guardedResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.markWritten()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *guardedResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.markWritten()
+		f.Flush()
+	}
+}
+
+func (w *guardedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *guardedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status code sent to the client, or 0 if nothing was sent.
+func (w *guardedResponseWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.status
+}
+
+// markWritten records an implicit 200 when the body is written without a status.
+// The caller must hold w.mu.
+func (w *guardedResponseWriter) markWritten() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = http.StatusOK
+	}
+}
+
+// expire blocks every later write. If nothing has been written yet, code becomes
+// the recorded status and respond, when given, is called with the underlying
+// writer so the framework can still send a final response; it reports whether
+// respond was called.
+func (w *guardedResponseWriter) expire(code int, respond func(w http.ResponseWriter)) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired {
+		return false
+	}
+	w.expired = true
+
+	if w.wroteHeader {
+		return false
+	}
+	w.status = code
+	if respond == nil {
+		return false
+	}
+	w.wroteHeader = true
+	respond(w.ResponseWriter)
+	return true
+}
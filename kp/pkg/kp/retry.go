@@ -0,0 +1,182 @@
+package kp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+// Kafka headers set on messages forwarded to a retry or dead-letter topic.
+// The headers of the original message are kept.
+const (
+	HeaderRetryAttempt  = "x-retry-attempt"  // handler runs so far, over every topic
+	HeaderRetryError    = "x-retry-error"    // error returned by the last run
+	HeaderOriginalTopic = "x-original-topic" // topic the message was first consumed from
+	HeaderRetryAfter    = "x-retry-after"    // unix milliseconds before which the message is not handled
+)
+
+// RetryPolicy configures what happens to messages whose handler returns an error.
+type RetryPolicy struct {
+	// Attempts is the number of times the handler runs in-process before the
+	// message moves to the next retry topic. Values below 1 mean 1.
+	Attempts int
+	// Backoff is the wait before the second in-process attempt. It doubles for
+	// every later attempt, up to MaxBackoff when that is set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Delays adds one retry topic per delay, named by RetryTopic, e.g.
+	// "orders.retry.1m". A message forwarded to a retry topic is handled again
+	// once its delay has passed, with the same in-process attempts.
+	Delays []time.Duration
+	// DeadLetter forwards messages that failed on every retry topic to the
+	// topic named by DeadLetterTopic. Otherwise they are logged and dropped.
+	DeadLetter bool
+}
+
+// WithRetry retries failed messages of the subscription according to policy.
+// The retry topics are consumed by the same handler and must exist, like the
+// dead-letter topic.
+func WithRetry(policy RetryPolicy) ConsumerOption {
+	return func(s *subscription) {
+		s.retry = &policy
+	}
+}
+
+// RetryTopic returns the name of the retry topic of topic with the given delay.
+func RetryTopic(topic string, delay time.Duration) string {
+	return topic + ".retry." + formatDelay(delay)
+}
+
+// DeadLetterTopic returns the name of the dead-letter topic of topic.
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+// formatDelay formats d without its zero trailing units: 1m instead of 1m0s.
+func formatDelay(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.Attempts < 1 {
+		return 1
+	}
+	return p.Attempts
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (p *RetryPolicy) backoff(failed int) time.Duration {
+	if p == nil || p.Backoff <= 0 {
+		return 0
+	}
+
+	d := p.Backoff
+	for i := 1; i < failed; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// topicName returns the topic consumed by the subscription.
+func (s *subscription) topicName() string {
+	if s.stage == 0 {
+		return s.topic
+	}
+	return RetryTopic(s.topic, s.retry.Delays[s.stage-1])
+}
+
+// retrySubscriptions returns the subscriptions consuming the retry topics of s.
+func (s *subscription) retrySubscriptions() []*subscription {
+	if s.retry == nil {
+		return nil
+	}
+
+	subs := make([]*subscription, 0, len(s.retry.Delays))
+	for i := range s.retry.Delays {
+		retry := *s
+		retry.stage = i + 1
+		subs = append(subs, &retry)
+	}
+	return subs
+}
+
+// forwardFailed sends msg, which failed attempts more times, to the next retry
+// topic of the subscription or to its dead-letter topic.
+func (kc *KafkaClient) forwardFailed(ctx context.Context, msg *kafka.Message, sub *subscription, attempts int, cause error) error {
+	if previous, err := strconv.Atoi(msg.Header(HeaderRetryAttempt)); err == nil {
+		attempts += previous
+	}
+	originalTopic := msg.Header(HeaderOriginalTopic)
+	if originalTopic == "" {
+		originalTopic = msg.Topic
+	}
+
+	headers := map[string]string{
+		HeaderRetryAttempt:  strconv.Itoa(attempts),
+		HeaderRetryError:    cause.Error(),
+		HeaderOriginalTopic: originalTopic,
+	}
+
+	var topic string
+	switch {
+	case sub.stage < len(sub.retry.Delays):
+		delay := sub.retry.Delays[sub.stage]
+		topic = RetryTopic(sub.topic, delay)
+		headers[HeaderRetryAfter] = strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10)
+	case sub.retry.DeadLetter:
+		topic = DeadLetterTopic(sub.topic)
+		// drop the delay of the last retry topic
+		msg = msg.WithoutHeaders(HeaderRetryAfter)
+	default:
+		kc.log.appLog.Errorf("dropping message of topic %s after %d attempts: %v", msg.Topic, attempts, cause)
+		return nil
+	}
+
+	// the message is committed once forwarded: do not let shutdown cancel the write
+	if err := kc.kafkaClient.Forward(context.WithoutCancel(ctx), topic, msg, headers); err != nil {
+		return fmt.Errorf("forward to %s: %w", topic, err)
+	}
+	kc.log.appLog.Logf("forwarded message of topic %s to %s after %d attempts: %v", msg.Topic, topic, attempts, cause)
+	return nil
+}
+
+// waitRetryDelay waits until msg may be handled according to its retry header.
+// It reports false if ctx is done first.
+func waitRetryDelay(ctx context.Context, msg *kafka.Message) bool {
+	after, err := strconv.ParseInt(msg.Header(HeaderRetryAfter), 10, 64)
+	if err != nil {
+		return true
+	}
+	return sleep(ctx, time.Until(time.UnixMilli(after)))
+}
+
+// sleep waits for d and reports false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,172 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	segmentio "github.com/segmentio/kafka-go"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+type countingCommitter struct{ commits *int }
+
+func (c countingCommitter) Commit() { *c.commits++ }
+
+func newRetryTestMessage(topic string, commits *int, headers ...segmentio.Header) *kafka.Message {
+	msg := kafka.NewMessage(context.Background(), segmentio.Message{Headers: headers})
+	msg.Topic = topic
+	msg.Value = []byte(`{"id":1}`)
+	msg.Committer = countingCommitter{commits: commits}
+	return msg
+}
+
+func TestRetryTopicNames(t *testing.T) {
+	tests := map[time.Duration]string{
+		30 * time.Second:             "orders.retry.30s",
+		time.Minute:                  "orders.retry.1m",
+		time.Hour:                    "orders.retry.1h",
+		90 * time.Minute:             "orders.retry.1h30m",
+		time.Minute + 30*time.Second: "orders.retry.1m30s",
+	}
+	for delay, want := range tests {
+		if got := RetryTopic("orders", delay); got != want {
+			t.Errorf("RetryTopic(%v) = %s, want %s", delay, got, want)
+		}
+	}
+	if got := DeadLetterTopic("orders"); got != "orders.dlq" {
+		t.Errorf("unexpected dead-letter topic %s", got)
+	}
+}
+
+func TestRetryForwardsToRetryTopic(t *testing.T) {
+	client := &MockKafkaClient{}
+	kc, _ := newTestKafkaClient(t, client)
+
+	runs := 0
+	sub := newSubscription("orders", func(c *Context) error {
+		runs++
+		return errors.New("db unavailable")
+	}, WithRetry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond, Delays: []time.Duration{time.Minute}, DeadLetter: true}))
+
+	commits := 0
+	msg := newRetryTestMessage("orders", &commits, segmentio.Header{Key: "x-transaction-id", Value: []byte("tx-1")})
+	kc.handleMessage(context.Background(), "orders", msg, sub)
+
+	if runs != 3 {
+		t.Errorf("expected 3 in-process attempts, got %d", runs)
+	}
+	if commits != 1 {
+		t.Errorf("expected the forwarded message to be committed, got %d commits", commits)
+	}
+	if len(client.ForwardCalls) != 1 {
+		t.Fatalf("expected 1 forward, got %d", len(client.ForwardCalls))
+	}
+
+	call := client.ForwardCalls[0]
+	if call.Topic != "orders.retry.1m" || call.Message != msg {
+		t.Errorf("unexpected forward to %s", call.Topic)
+	}
+	if call.Headers[HeaderRetryAttempt] != "3" || call.Headers[HeaderRetryError] != "db unavailable" || call.Headers[HeaderOriginalTopic] != "orders" {
+		t.Errorf("unexpected retry headers %v", call.Headers)
+	}
+	after, _ := strconv.ParseInt(call.Headers[HeaderRetryAfter], 10, 64)
+	if d := time.Until(time.UnixMilli(after)); d < 50*time.Second || d > time.Minute {
+		t.Errorf("expected the message to be delayed by a minute, got %v", d)
+	}
+}
+
+func TestRetryForwardsToDeadLetterTopic(t *testing.T) {
+	client := &MockKafkaClient{}
+	kc, _ := newTestKafkaClient(t, client)
+
+	sub := newSubscription("orders", func(c *Context) error {
+		return errors.New("invalid order")
+	}, WithRetry(RetryPolicy{Delays: []time.Duration{time.Minute}, DeadLetter: true}))
+	retry := sub.retrySubscriptions()[0]
+	if retry.topicName() != "orders.retry.1m" {
+		t.Fatalf("unexpected retry subscription topic %s", retry.topicName())
+	}
+
+	commits := 0
+	msg := newRetryTestMessage("orders.retry.1m", &commits,
+		segmentio.Header{Key: HeaderRetryAttempt, Value: []byte("1")},
+		segmentio.Header{Key: HeaderOriginalTopic, Value: []byte("orders")},
+		segmentio.Header{Key: HeaderRetryAfter, Value: []byte(strconv.FormatInt(time.Now().Add(-time.Second).UnixMilli(), 10))},
+	)
+	kc.handleMessage(context.Background(), "orders.retry.1m", msg, retry)
+
+	if commits != 1 || len(client.ForwardCalls) != 1 {
+		t.Fatalf("expected 1 forward and 1 commit, got %d and %d", len(client.ForwardCalls), commits)
+	}
+	call := client.ForwardCalls[0]
+	if call.Topic != "orders.dlq" || call.Headers[HeaderRetryAttempt] != "2" || call.Headers[HeaderOriginalTopic] != "orders" {
+		t.Errorf("unexpected dead-letter forward to %s with %v", call.Topic, call.Headers)
+	}
+	if _, ok := call.Headers[HeaderRetryAfter]; ok || call.Message.Header(HeaderRetryAfter) != "" {
+		t.Errorf("expected the retry delay to be removed, got %v and %v", call.Headers, call.Message.Headers())
+	}
+}
+
+func TestRetryKeepsMessageWhenForwardFails(t *testing.T) {
+	client := &MockKafkaClient{ForwardError: errors.New("broker down")}
+	kc, log := newTestKafkaClient(t, client)
+
+	sub := newSubscription("orders", func(c *Context) error {
+		return errors.New("invalid order")
+	}, WithRetry(RetryPolicy{DeadLetter: true}))
+
+	commits := 0
+	kc.handleMessage(context.Background(), "orders", newRetryTestMessage("orders", &commits), sub)
+
+	if commits != 0 {
+		t.Errorf("expected the message not to be committed, got %d commits", commits)
+	}
+	if appLog := log.appLog.(*MockLoggerService); len(appLog.ErrorfCalls) != 2 {
+		t.Errorf("expected handler and forward errors to be logged, got %d", len(appLog.ErrorfCalls))
+	}
+}
+
+func TestRetryWaitsForDelay(t *testing.T) {
+	kc, _ := newTestKafkaClient(t, &MockKafkaClient{})
+
+	runs := 0
+	sub := newSubscription("orders", func(c *Context) error {
+		runs++
+		return nil
+	}, WithRetry(RetryPolicy{Delays: []time.Duration{time.Minute}}))
+
+	commits := 0
+	msg := newRetryTestMessage("orders.retry.1m", &commits,
+		segmentio.Header{Key: HeaderRetryAfter, Value: []byte(strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10))},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	kc.handleMessage(ctx, "orders.retry.1m", msg, sub.retrySubscriptions()[0])
+
+	if runs != 0 || commits != 0 {
+		t.Errorf("expected the delayed message to be left for redelivery, got %d runs and %d commits", runs, commits)
+	}
+}
+
+func TestConsumerRegistersRetryTopics(t *testing.T) {
+	app := newTestApp(t)
+	app.kafkaClient, _ = newTestKafkaClient(t, &MockKafkaClient{})
+
+	app.Consumer("orders", func(c *Context) error { return nil },
+		WithRetry(RetryPolicy{Delays: []time.Duration{time.Minute, 10 * time.Minute}, DeadLetter: true}))
+
+	want := []string{"orders", "orders.retry.10m", "orders.retry.1m"}
+	got := app.kafkaClient.topics()
+	if len(got) != len(want) {
+		t.Fatalf("expected topics %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected topics %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package kp
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	goHttp "github.com/sing3demons/go-common-kp/kp/pkg/http"
+)
+
+// IRouter registers HTTP routes. It is implemented by the application itself
+// and by every group returned from Group.
+type IRouter interface {
+	Use(mws ...Middleware)
+	Group(prefix string, mws ...Middleware) IRouter
+	Get(pattern string, handler Handler, mws ...Middleware) *Route
+	Post(pattern string, handler Handler, mws ...Middleware) *Route
+	Put(pattern string, handler Handler, mws ...Middleware) *Route
+	Patch(pattern string, handler Handler, mws ...Middleware) *Route
+	Delete(pattern string, handler Handler, mws ...Middleware) *Route
+}
+
+// Route is a registered HTTP route; its methods tune how the route is served.
+type Route struct {
+	handler *handler
+}
+
+// Timeout overrides the request timeout configured by Server.RequestTimeout for
+// this route. A zero or negative duration disables the timeout, and the write
+// deadline of the server for the requests of this route only.
+func (r *Route) Timeout(d time.Duration) *Route {
+	r.handler.requestTimeout = d
+	return r
+}
+
+// routeGroup is a set of routes sharing a path prefix and a middleware stack.
+// Groups can be nested; a route in a group runs the app-wide middlewares, then
+// the middlewares of every enclosing group from the outermost in, then its own.
+type routeGroup struct {
+	app         *App
+	router      *goHttp.Router
+	parent      func() []Middleware
+	mu          sync.RWMutex
+	middlewares []Middleware
+}
+
+func newRouteGroup(app *App, router *goHttp.Router, parent func() []Middleware, mws []Middleware) *routeGroup {
+	return &routeGroup{
+		app:         app,
+		router:      router,
+		parent:      parent,
+		middlewares: mws,
+	}
+}
+
+// stack returns the middlewares inherited by routes of the group.
+func (g *routeGroup) stack() []Middleware {
+	g.mu.RLock()
+	own := g.middlewares
+	g.mu.RUnlock()
+	return stackMiddlewares(g.parent, own)()
+}
+
+// Use registers middlewares for the routes of the group. Like App.Use, it
+// must be called before Start.
+func (g *routeGroup) Use(mws ...Middleware) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.middlewares = append(g.middlewares[:len(g.middlewares):len(g.middlewares)], mws...)
+}
+
+func (g *routeGroup) Group(prefix string, mws ...Middleware) IRouter {
+	return newRouteGroup(g.app, g.router.Group(prefix), g.stack, mws)
+}
+
+func (g *routeGroup) Get(pattern string, handler Handler, mws ...Middleware) *Route {
+	return g.app.addRoute(g.router, http.MethodGet, pattern, handler, stackMiddlewares(g.stack, mws))
+}
+func (g *routeGroup) Post(pattern string, handler Handler, mws ...Middleware) *Route {
+	return g.app.addRoute(g.router, http.MethodPost, pattern, handler, stackMiddlewares(g.stack, mws))
+}
+func (g *routeGroup) Put(pattern string, handler Handler, mws ...Middleware) *Route {
+	return g.app.addRoute(g.router, http.MethodPut, pattern, handler, stackMiddlewares(g.stack, mws))
+}
+func (g *routeGroup) Patch(pattern string, handler Handler, mws ...Middleware) *Route {
+	return g.app.addRoute(g.router, http.MethodPatch, pattern, handler, stackMiddlewares(g.stack, mws))
+}
+func (g *routeGroup) Delete(pattern string, handler Handler, mws ...Middleware) *Route {
+	return g.app.addRoute(g.router, http.MethodDelete, pattern, handler, stackMiddlewares(g.stack, mws))
+}
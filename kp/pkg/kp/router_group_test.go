@@ -0,0 +1,62 @@
+package kp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouteGroup(t *testing.T) {
+	app := newTestApp(t)
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(c *Context) error {
+				calls = append(calls, name)
+				return next(c)
+			}
+		}
+	}
+
+	app.Use(record("app"))
+	v1 := app.Group("/v1", record("v1"))
+	users := v1.Group("/users", record("users"))
+	users.Get("/{id}", func(c *Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"id": c.PathParam("id")})
+	}, record("route"))
+	users.Use(record("users-late"))
+
+	v2 := app.Group("/v2")
+	v2.Get("/users/{id}", func(c *Context) error {
+		return c.JSON(http.StatusOK, "v2")
+	})
+
+	tests := []struct {
+		target string
+		status int
+		calls  string
+		body   string
+	}{
+		{target: "/v1/users/42", status: http.StatusOK, calls: "app,v1,users,users-late,route", body: `"id":"42"`},
+		{target: "/v2/users/42", status: http.StatusOK, calls: "app", body: `"v2"`},
+		{target: "/users/42", status: http.StatusNotFound, calls: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			calls = nil
+			rec := serve(app, http.MethodGet, tt.target)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := strings.Join(calls, ","); got != tt.calls {
+				t.Errorf("expected calls %q, got %q", tt.calls, got)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("expected body to contain %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Shutdown stops the application in order:
+//  1. readiness starts failing,
+//  2. Kafka consumers stop fetching,
+//  3. the HTTP server drains in-flight requests,
+//  4. in-flight Kafka handlers finish and commit their offsets,
+//  5. the OnShutdown hooks run,
+//  6. the Kafka client flushes its writer and closes readers and connections,
+//  7. pending spans are exported,
+//  8. loggers are synced.
+//
+// Every step runs even if a previous one failed; ctx bounds the whole sequence.
+// Only the first call performs the shutdown, later calls return its result.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		a.shutdownErr = a.shutdown(ctx)
+	})
+	return a.shutdownErr
+}
+
+func (a *App) shutdown(ctx context.Context) error {
+	var err error
+
+	a.health.shuttingDown.Store(true)
+
+	if a.stopConsumers != nil {
+		a.stopConsumers()
+	}
+
+	if a.httpServer != nil {
+		if e := a.httpServer.Shutdown(ctx); e != nil {
+			err = errors.Join(err, fmt.Errorf("http server: %w", e))
+		}
+	}
+
+	if a.kafkaClient != nil {
+		if e := a.waitConsumers(ctx); e != nil {
+			err = errors.Join(err, e)
+		}
+	}
+
+	if e := a.runShutdownHooks(ctx); e != nil {
+		err = errors.Join(err, e)
+	}
+
+	if a.kafkaClient != nil {
+		if e := a.kafkaClient.kafkaClient.Close(); e != nil {
+			err = errors.Join(err, fmt.Errorf("kafka client: %w", e))
+		}
+	}
+
+	if a.traceProvider != nil {
+		if e := a.traceProvider.Shutdown(ctx); e != nil {
+			err = errors.Join(err, fmt.Errorf("tracer provider: %w", e))
+		}
+	}
+
+	// Sync errors are ignored: syncing stdout fails on most platforms.
+	_ = a.AppLog.Sync()
+	_ = a.DetailLog.Sync()
+	_ = a.SummaryLog.Sync()
+
+	return err
+}
+
+// waitConsumers waits until the consumer loops, and the handlers they run, have returned.
+func (a *App) waitConsumers(ctx context.Context) error {
+	if a.consumersDone == nil {
+		return nil
+	}
+
+	select {
+	case <-a.consumersDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for kafka handlers: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,65 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForConsumers(t *testing.T) {
+	app := newTestApp(t)
+	client := &MockKafkaClient{}
+	app.kafkaClient, _ = newTestKafkaClient(t, client)
+
+	stopped := make(chan struct{})
+	_, cancel := context.WithCancel(context.Background())
+	app.stopConsumers = func() {
+		cancel()
+		close(stopped)
+	}
+	app.consumersDone = make(chan struct{})
+
+	go func() {
+		<-stopped
+		time.Sleep(20 * time.Millisecond)
+		close(app.consumersDone)
+	}()
+
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	select {
+	case <-app.consumersDone:
+	default:
+		t.Fatal("shutdown returned before the consumers finished")
+	}
+	if client.CloseCalls != 1 {
+		t.Errorf("expected kafka client to be closed once, got %d", client.CloseCalls)
+	}
+	if !app.health.shuttingDown.Load() {
+		t.Error("expected readiness to be switched off")
+	}
+
+	// later calls return the first result without running the sequence again
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected second shutdown to succeed, got %v", err)
+	}
+	if client.CloseCalls != 1 {
+		t.Errorf("expected kafka client to be closed once, got %d", client.CloseCalls)
+	}
+}
+
+func TestShutdownTimesOutOnStuckConsumers(t *testing.T) {
+	app := newTestApp(t)
+	app.kafkaClient, _ = newTestKafkaClient(t, &MockKafkaClient{})
+	app.consumersDone = make(chan struct{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := app.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
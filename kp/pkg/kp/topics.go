@@ -0,0 +1,169 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// defaultDiscoveryInterval is how often the topics of the cluster are listed
+// to find new topics matching a pattern subscription.
+const defaultDiscoveryInterval = time.Minute
+
+// ErrSubscriptionExists is returned when a topic or pattern is subscribed twice.
+var ErrSubscriptionExists = errors.New("subscription already exists")
+
+// derivedTopic matches the retry and dead-letter topics, which pattern
+// subscriptions never consume directly.
+var derivedTopic = regexp.MustCompile(`\.(retry\.[0-9a-zµ.]+|dlq)$`)
+
+// WithDiscoveryInterval sets how often a pattern subscription looks for new
+// matching topics. It defaults to one minute and has no effect on Consumer.
+func WithDiscoveryInterval(d time.Duration) ConsumerOption {
+	return func(s *subscription) {
+		s.discoveryInterval = d
+	}
+}
+
+// patternSubscription consumes every topic matching pattern with the options
+// of its template subscription.
+type patternSubscription struct {
+	pattern  *regexp.Regexp
+	template *subscription
+}
+
+// topicSet records the topics consumed once the consumers are started, so a
+// topic matched by discovery is consumed only once.
+type topicSet struct {
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+// add adds topic to the set and reports whether it was not there yet.
+func (s *topicSet) add(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.topics == nil {
+		s.topics = make(map[string]bool)
+	}
+	if s.topics[topic] {
+		return false
+	}
+	s.topics[topic] = true
+	return true
+}
+
+// subscribe registers subs, with their retry subscriptions, or none of them if
+// one of their topics is already subscribed.
+func (kc *KafkaClient) subscribe(subs ...*subscription) error {
+	all := make([]*subscription, 0, len(subs))
+	for _, sub := range subs {
+		all = append(all, sub)
+		all = append(all, sub.retrySubscriptions()...)
+	}
+
+	seen := make(map[string]bool, len(all))
+	for _, sub := range all {
+		topic := sub.topicName()
+		if _, exists := kc.subscriptions[topic]; exists || seen[topic] {
+			return fmt.Errorf("topic %s: %w", topic, ErrSubscriptionExists)
+		}
+		seen[topic] = true
+	}
+
+	for _, sub := range all {
+		kc.subscriptions[sub.topicName()] = sub
+	}
+	return nil
+}
+
+// ensureTopics creates the subscribed topics, and the dead-letter topics of
+// their retry policies, that do not exist yet.
+func (kc *KafkaClient) ensureTopics(ctx context.Context) error {
+	topics := kc.topics()
+	for _, sub := range kc.subscriptions {
+		if sub.stage == 0 && sub.retry != nil && sub.retry.DeadLetter {
+			topics = append(topics, DeadLetterTopic(sub.topic))
+		}
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+	return kc.kafkaClient.EnsureTopics(ctx, topics...)
+}
+
+// subscribePattern registers a subscription to the topics matching pattern.
+func (kc *KafkaClient) subscribePattern(pattern string, sub *subscription) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid topic pattern %q: %w", pattern, err)
+	}
+
+	for _, p := range kc.patterns {
+		if p.pattern.String() == pattern {
+			return fmt.Errorf("topic pattern %s: %w", pattern, ErrSubscriptionExists)
+		}
+	}
+
+	kc.patterns = append(kc.patterns, &patternSubscription{pattern: re, template: sub})
+	return nil
+}
+
+// watchPattern starts a consumer, through start, for every topic matching p
+// now and every discovery interval until ctx is done.
+func (kc *KafkaClient) watchPattern(ctx context.Context, p *patternSubscription, start func(topic string, sub *subscription)) {
+	interval := p.template.discoveryInterval
+	if interval <= 0 {
+		interval = defaultDiscoveryInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		kc.discover(ctx, p, start)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover lists the topics of the cluster and starts a consumer for each new
+// topic matching p. Topics already consumed, by an explicit subscription or by
+// an earlier pattern, are left to their consumer.
+func (kc *KafkaClient) discover(ctx context.Context, p *patternSubscription, start func(topic string, sub *subscription)) {
+	topics, err := kc.kafkaClient.ListTopics(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			kc.log.appLog.Errorf("error listing topics for pattern %s: %v", p.pattern, err)
+		}
+		return
+	}
+
+	for _, topic := range topics {
+		if !p.pattern.MatchString(topic) || derivedTopic.MatchString(topic) {
+			continue
+		}
+		if !kc.consumed.add(topic) {
+			continue
+		}
+
+		sub := *p.template
+		sub.topic = topic
+		kc.log.appLog.Logf("discovered topic %s for pattern %s", topic, p.pattern)
+		start(topic, &sub)
+
+		for _, retry := range sub.retrySubscriptions() {
+			if kc.consumed.add(retry.topicName()) {
+				start(retry.topicName(), retry)
+			}
+		}
+	}
+}
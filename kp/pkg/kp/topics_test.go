@@ -0,0 +1,148 @@
+package kp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestConsumerRejectsDuplicateTopic(t *testing.T) {
+	app := newTestApp(t)
+	app.kafkaClient, _ = newTestKafkaClient(t, &MockKafkaClient{})
+	handler := func(c *Context) error { return nil }
+
+	if err := app.Consumer("orders", handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := app.Consumer("orders", handler)
+	if !errors.Is(err, ErrSubscriptionExists) {
+		t.Fatalf("expected ErrSubscriptionExists, got %v", err)
+	}
+	if calls := app.AppLog.(*MockLoggerService).ErrorfCalls; len(calls) != 1 {
+		t.Errorf("expected the conflict to be logged as an error, got %d error logs", len(calls))
+	}
+}
+
+func TestConsumerTopicsRegistersAllOrNone(t *testing.T) {
+	app := newTestApp(t)
+	app.kafkaClient, _ = newTestKafkaClient(t, &MockKafkaClient{})
+	handler := func(c *Context) error { return nil }
+
+	if err := app.Consumer("payments", handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := app.ConsumerTopics([]string{"orders", "payments"}, handler); !errors.Is(err, ErrSubscriptionExists) {
+		t.Fatalf("expected ErrSubscriptionExists, got %v", err)
+	}
+	if got := app.kafkaClient.topics(); !reflect.DeepEqual(got, []string{"payments"}) {
+		t.Fatalf("expected no topic registered on conflict, got %v", got)
+	}
+
+	if err := app.ConsumerTopics([]string{"orders", "refunds"}, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := app.kafkaClient.topics(), []string{"orders", "payments", "refunds"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected topics %v, got %v", want, got)
+	}
+}
+
+func TestConsumerPatternErrors(t *testing.T) {
+	app := newTestApp(t)
+	handler := func(c *Context) error { return nil }
+
+	if err := app.ConsumerPattern(`^orders\..*`, handler); !errors.Is(err, errKafkaNotConfigured) {
+		t.Fatalf("expected errKafkaNotConfigured, got %v", err)
+	}
+
+	app.kafkaClient, _ = newTestKafkaClient(t, &MockKafkaClient{})
+	if err := app.ConsumerPattern(`^orders\.(`, handler); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+	if err := app.ConsumerPattern(`^orders\..*`, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := app.ConsumerPattern(`^orders\..*`, handler); !errors.Is(err, ErrSubscriptionExists) {
+		t.Fatalf("expected ErrSubscriptionExists, got %v", err)
+	}
+}
+
+func TestDiscoverStartsNewMatchingTopics(t *testing.T) {
+	mock := &MockKafkaClient{ListTopicsResult: []string{
+		"orders.created", "orders.paid", "orders.created.retry.1m", "orders.created.dlq", "payments",
+	}}
+	kc, _ := newTestKafkaClient(t, mock)
+	handler := func(c *Context) error { return nil }
+
+	if err := kc.subscribe(newSubscription("orders.paid", handler)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	kc.consumed.add("orders.paid")
+	if err := kc.subscribePattern(`^orders\.`, newSubscription("", handler,
+		WithRetry(RetryPolicy{Delays: []time.Duration{time.Minute}}))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var started []string
+	start := func(topic string, sub *subscription) {
+		if sub.topicName() != topic {
+			t.Errorf("started topic %s with a subscription to %s", topic, sub.topicName())
+		}
+		started = append(started, topic)
+	}
+
+	kc.discover(context.Background(), kc.patterns[0], start)
+	sort.Strings(started)
+	if want := []string{"orders.created", "orders.created.retry.1m"}; !reflect.DeepEqual(started, want) {
+		t.Fatalf("expected %v started, got %v", want, started)
+	}
+
+	started = nil
+	mock.ListTopicsResult = append(mock.ListTopicsResult, "orders.refunded")
+	kc.discover(context.Background(), kc.patterns[0], start)
+	sort.Strings(started)
+	if want := []string{"orders.refunded", "orders.refunded.retry.1m"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("expected only the new topic started, got %v", started)
+	}
+}
+
+func TestDiscoverLogsListError(t *testing.T) {
+	kc, log := newTestKafkaClient(t, &MockKafkaClient{ListTopicsError: errors.New("broker down")})
+	if err := kc.subscribePattern(`^orders\.`, newSubscription("", func(c *Context) error { return nil })); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	kc.discover(context.Background(), kc.patterns[0], func(string, *subscription) {
+		t.Error("expected no consumer started")
+	})
+	if calls := log.appLog.(*MockLoggerService).ErrorfCalls; len(calls) != 1 {
+		t.Errorf("expected the list error to be logged, got %d error logs", len(calls))
+	}
+}
+
+func TestEnsureTopicsIncludesDeadLetterTopics(t *testing.T) {
+	mock := &MockKafkaClient{}
+	kc, _ := newTestKafkaClient(t, mock)
+	handler := func(c *Context) error { return nil }
+
+	if err := kc.subscribe(
+		newSubscription("orders", handler, WithRetry(RetryPolicy{Delays: []time.Duration{time.Minute}, DeadLetter: true})),
+		newSubscription("payments", handler),
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := kc.ensureTopics(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.EnsureTopicsCalls) != 1 {
+		t.Fatalf("expected one EnsureTopics call, got %d", len(mock.EnsureTopicsCalls))
+	}
+	got := mock.EnsureTopicsCalls[0]
+	sort.Strings(got)
+	if want := []string{"orders", "orders.dlq", "orders.retry.1m", "payments"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected topics %v, got %v", want, got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,7 +23,12 @@ type CustomLoggerService interface {
 	SetSummary(params LogEventTag) CustomLoggerService
 	AddField(key string, value any)
 }
+
+// customLoggerService is safe for concurrent use: a handler that outlives
+// its request timeout may still log while the summary log is being closed.
 type customLoggerService struct {
+	mu sync.Mutex
+
 	logDto                    LogDto
 	metaData                  Metadata
 	isSetSummaryLogParameters bool
@@ -76,16 +82,27 @@ func NewCustomLogger(detailLog LoggerService, summaryLog LoggerService, time *Ti
 	}
 }
 
+// Init sets the fields of every log entry. The metadata is kept for the
+// summary log only.
 func (c *customLoggerService) Init(data LogDto) {
-	c.metaData = c.logDto.Metadata
-	c.logDto.Metadata = Metadata{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metaData = data.Metadata
 	c.logDto = data
+	c.logDto.Metadata = Metadata{}
 }
 
 func (c *customLoggerService) GetLogDto() LogDto {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	return c.logDto
 }
 func (c *customLoggerService) Update(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	v := reflect.ValueOf(&c.logDto).Elem()
 	field := v.FieldByName(key)
 	if field.IsValid() && field.CanSet() {
@@ -94,12 +111,18 @@ func (c *customLoggerService) Update(key string, value any) {
 }
 
 func (c *customLoggerService) Info(action LoggerAction, data any, options ...MaskingOptionDto) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.logDto.Metadata = Metadata{}
 	c.detailLog.Info(c.toStr(action, data, options...))
 	c.logDto.SubAction = ""
 }
 
 func (c *customLoggerService) AddField(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.additionalSummary == nil {
 		c.additionalSummary = make(map[string]any)
 	}
@@ -122,11 +145,17 @@ func (c *customLoggerService) toStr(action LoggerAction, data any, options ...Ma
 	return string(jsonBytes)
 }
 func (c *customLoggerService) Debug(action LoggerAction, data any, options ...MaskingOptionDto) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.logDto.Metadata = Metadata{}
 	c.detailLog.Debug(c.toStr(action, data, options...))
 	c.logDto.SubAction = ""
 }
 func (c *customLoggerService) Error(action LoggerAction, data any, options ...MaskingOptionDto) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.logDto.Metadata = Metadata{}
 	c.detailLog.Error(c.toStr(action, data, options...))
 	c.logDto.SubAction = ""
@@ -141,6 +170,9 @@ func (c *customLoggerService) Flush() {
 }
 
 func (c *customLoggerService) SetSummary(param LogEventTag) CustomLoggerService {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.summaryLogAdditionalInfo == nil {
 		c.summaryLogAdditionalInfo = make([]Sequence, 0)
 	}
@@ -290,6 +322,9 @@ func expandResultCode(code int) resultCodeType {
 }
 
 func (c *customLoggerService) End(code int, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	result := expandResultCode(code)
 	if message == "" {
 		message = result.Message
@@ -299,6 +334,10 @@ func (c *customLoggerService) End(code int, message string) {
 		Message: message,
 		Status:  result.StatusCode,
 	}
+	if c.logDto.AppResultCode != "" {
+		// a business result code set through Update takes precedence over the derived one
+		stack.Code = c.logDto.AppResultCode
+	}
 	summaryLog := NewSummaryLogService(c.summaryLog, c, c.maskingService)
 	summaryLog.Init(c.logDto)
 	summaryLog.Flush(stack)
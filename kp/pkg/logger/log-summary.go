@@ -124,6 +124,7 @@ func (s *summaryLogService) Flush(data Stack) {
 	}
 
 	s.clearNonSummaryLogParam()
+	s.logDto.Metadata = s.customLogger.metaData
 	s.logDto.ThreadId = getGoroutineID()
 	jsonBytes, err := json.Marshal(s.logDto)
 	if err == nil {
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct {
+	vec *vec[float64]
+}
+
+// Inc adds one to the series identified by labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series identified by labelValues.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.vec.name))
+	}
+
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+
+	c.vec.with(labelValues, nil).value += v
+}
+
+// Value returns the current value of the series identified by labelValues.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+
+	return c.vec.with(labelValues, nil).value
+}
+
+func (c *Counter) describe() (string, string, string) {
+	return c.vec.name, c.vec.help, "counter"
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+
+	for _, s := range c.vec.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.vec.name, formatLabels(c.vec.labelNames, s.labelValues), formatValue(s.value))
+	}
+}
+
+// Gauge is a value per label combination that can go up and down.
+type Gauge struct {
+	vec *vec[float64]
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+
+	g.vec.with(labelValues, nil).value = v
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+
+	g.vec.with(labelValues, nil).value += v
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Value returns the current value of the series identified by labelValues.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+
+	return g.vec.with(labelValues, nil).value
+}
+
+func (g *Gauge) describe() (string, string, string) {
+	return g.vec.name, g.vec.help, "gauge"
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.vec.mu.Lock()
+	defer g.vec.mu.Unlock()
+
+	for _, s := range g.vec.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", g.vec.name, formatLabels(g.vec.labelNames, s.labelValues), formatValue(s.value))
+	}
+}
+
+// Histogram counts observations into cumulative buckets per label combination.
+type Histogram struct {
+	vec     *vec[*histogramValue]
+	buckets []float64
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(name, help string, buckets []float64, labelNames []string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Histogram{
+		vec:     newVec[*histogramValue](name, help, labelNames),
+		buckets: buckets,
+	}
+}
+
+// Observe records v in the series identified by labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+
+	hv := h.vec.with(labelValues, h.newValue).value
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// Count returns the number of observations of the series identified by labelValues.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+
+	return h.vec.with(labelValues, h.newValue).value.count
+}
+
+func (h *Histogram) newValue() *histogramValue {
+	return &histogramValue{counts: make([]uint64, len(h.buckets))}
+}
+
+func (h *Histogram) describe() (string, string, string) {
+	return h.vec.name, h.vec.help, "histogram"
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+
+	names := h.vec.labelNames
+	for _, s := range h.vec.sorted() {
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, formatLabels(names, s.labelValues, "le", formatValue(upper)), s.value.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.vec.name, formatLabels(names, s.labelValues, "le", "+Inf"), s.value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.vec.name, formatLabels(names, s.labelValues), formatValue(s.value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.vec.name, formatLabels(names, s.labelValues), s.value.count)
+	}
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
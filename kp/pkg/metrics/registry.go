@@ -0,0 +1,219 @@
+// Package metrics is a small, dependency free metrics registry that exposes
+// counters, gauges and histograms in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default histogram buckets, in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	describe() (name, help, kind string)
+	write(w io.Writer)
+}
+
+// Registry holds named metrics. Metrics are created on first use and returned
+// as-is on later calls with the same name, so handlers can look them up per
+// request. Asking for an existing name with a different type panics.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+	onCollect  []func()
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Counter returns the counter with the given name, creating it if needed.
+func (r *Registry) Counter(name, help string, labelNames ...string) *Counter {
+	return getOrCreate(r, name, func() *Counter {
+		return &Counter{vec: newVec[float64](name, help, labelNames)}
+	})
+}
+
+// Gauge returns the gauge with the given name, creating it if needed.
+func (r *Registry) Gauge(name, help string, labelNames ...string) *Gauge {
+	return getOrCreate(r, name, func() *Gauge {
+		return &Gauge{vec: newVec[float64](name, help, labelNames)}
+	})
+}
+
+// Histogram returns the histogram with the given name, creating it if needed.
+// Nil buckets default to DefBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return getOrCreate(r, name, func() *Histogram {
+		return newHistogram(name, help, buckets, labelNames)
+	})
+}
+
+// OnCollect registers fn to run before every exposition, e.g. to refresh gauges
+// from client statistics.
+func (r *Registry) OnCollect(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onCollect = append(r.onCollect, fn)
+}
+
+// Write writes every metric in the Prometheus text exposition format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	hooks := append([]func(){}, r.onCollect...)
+	r.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	collectors := make([]collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	for _, c := range collectors {
+		name, help, kind := c.describe()
+		if help != "" {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+		c.write(w)
+	}
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.Write(w)
+	})
+}
+
+func getOrCreate[T collector](r *Registry, name string, create func() T) T {
+	r.mu.RLock()
+	c, ok := r.collectors[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		r.mu.Lock()
+		c, ok = r.collectors[name]
+		if !ok {
+			c = create()
+			r.collectors[name] = c
+		}
+		r.mu.Unlock()
+	}
+
+	m, ok := c.(T)
+	if !ok {
+		_, _, kind := c.describe()
+		panic(fmt.Sprintf("metrics: %s is already registered as a %s", name, kind))
+	}
+	return m
+}
+
+// vec stores one value per combination of label values.
+type vec[T any] struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	labelValues []string
+	value       T
+}
+
+func newVec[T any](name, help string, labelNames []string) *vec[T] {
+	return &vec[T]{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     make(map[string]*series[T]),
+	}
+}
+
+// with returns the series for labelValues, creating it with init if needed.
+// The caller must hold v.mu.
+func (v *vec[T]) with(labelValues []string, init func() T) *series[T] {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		var value T
+		if init != nil {
+			value = init()
+		}
+		s = &series[T]{labelValues: append([]string(nil), labelValues...), value: value}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by label values for a stable output.
+// The caller must hold v.mu.
+func (v *vec[T]) sorted() []*series[T] {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := make([]*series[T], 0, len(keys))
+	for _, key := range keys {
+		out = append(out, v.series[key])
+	}
+	return out
+}
+
+// formatLabels formats label pairs, with optional extra pairs appended (e.g. "le").
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
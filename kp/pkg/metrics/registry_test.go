@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+
+	r.Counter("orders_total", "Orders created.", "channel").Inc("web")
+	r.Counter("orders_total", "Orders created.", "channel").Add(2, "web")
+	r.Gauge("queue_depth", "Items waiting.").Set(3)
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	h.Observe(0.05, "read")
+	h.Observe(0.5, "read")
+
+	var sb strings.Builder
+	r.Write(&sb)
+
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="read",le="0.1"} 1
+latency_seconds_bucket{op="read",le="1"} 2
+latency_seconds_bucket{op="read",le="+Inf"} 2
+latency_seconds_sum{op="read"} 0.55
+latency_seconds_count{op="read"} 2
+# HELP orders_total Orders created.
+# TYPE orders_total counter
+orders_total{channel="web"} 3
+# HELP queue_depth Items waiting.
+# TYPE queue_depth gauge
+queue_depth 3
+`
+	if got := sb.String(); got != want {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistryOnCollect(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("lag", "", "topic")
+	r.OnCollect(func() { g.Set(42, "orders") })
+
+	var sb strings.Builder
+	r.Write(&sb)
+
+	if !strings.Contains(sb.String(), `lag{topic="orders"} 42`) {
+		t.Errorf("expected collected gauge, got:\n%s", sb.String())
+	}
+}
+
+func TestRegistryTypeConflict(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests", "")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when registering a gauge over a counter")
+		}
+	}()
+	r.Gauge("requests", "")
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("errors_total", "", "reason").Inc("bad \"input\"\n")
+
+	var sb strings.Builder
+	r.Write(&sb)
+
+	if !strings.Contains(sb.String(), `errors_total{reason="bad \"input\"\n"} 1`) {
+		t.Errorf("expected escaped label, got:\n%s", sb.String())
+	}
+}
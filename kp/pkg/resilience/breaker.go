@@ -0,0 +1,145 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultOpenTimeout = 30 * time.Second
+
+// BreakerConfig configures the circuit breaker of a Policy.
+type BreakerConfig struct {
+	// FailureThreshold consecutive failed attempts open the circuit. 0
+	// disables the circuit breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before it lets probes
+	// through. It defaults to 30s.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probes let through while half-open.
+	// The circuit closes once they all succeed and opens again on the first
+	// failure. It defaults to 1.
+	HalfOpenProbes int
+}
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+type breaker struct {
+	mu       sync.Mutex
+	conf     BreakerConfig
+	state    State
+	failures int // consecutive failures while closed
+	openedAt time.Time
+	// probes counts the probes let through while half-open, and succeeded
+	// those that succeeded.
+	probes    int
+	succeeded int
+	// generation changes with the state, so results of attempts let through
+	// in an earlier state are ignored.
+	generation uint64
+	onChange   func(from, to State)
+	now        func() time.Time
+}
+
+func newBreaker(conf BreakerConfig, onChange func(from, to State)) *breaker {
+	if conf.OpenTimeout <= 0 {
+		conf.OpenTimeout = defaultOpenTimeout
+	}
+	if conf.HalfOpenProbes <= 0 {
+		conf.HalfOpenProbes = 1
+	}
+	return &breaker{conf: conf, onChange: onChange, now: time.Now}
+}
+
+func (b *breaker) currentState() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// allow reports whether an attempt may call the dependency. When it may, done
+// must be called with the outcome of the attempt.
+func (b *breaker) allow() (done func(success bool), ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.conf.OpenTimeout {
+		b.setState(StateHalfOpen)
+	}
+
+	switch b.state {
+	case StateOpen:
+		return nil, false
+	case StateHalfOpen:
+		if b.probes >= b.conf.HalfOpenProbes {
+			return nil, false
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	return func(success bool) { b.record(generation, success) }, true
+}
+
+func (b *breaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		if b.failures++; b.failures >= b.conf.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if !success {
+			b.setState(StateOpen)
+			return
+		}
+		if b.succeeded++; b.succeeded >= b.conf.HalfOpenProbes {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// setState moves the breaker to state and resets its counters. b.mu must be held.
+func (b *breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.failures, b.probes, b.succeeded = 0, 0, 0
+	if state == StateOpen {
+		b.openedAt = b.now()
+	}
+
+	if b.onChange != nil {
+		b.onChange(from, state)
+	}
+}
@@ -0,0 +1,60 @@
+package resilience
+
+import "github.com/sing3demons/go-common-kp/kp/pkg/metrics"
+
+// Results of the attempts counted by resilience_calls_total.
+const (
+	resultSuccess      = "success"
+	resultFailure      = "failure"
+	resultTimeout      = "timeout"
+	resultCircuitOpen  = "circuit_open"
+	resultBulkheadFull = "bulkhead_full"
+)
+
+type policyMetrics struct {
+	calls    *metrics.Counter
+	retries  *metrics.Counter
+	state    *metrics.Gauge
+	inflight *metrics.Gauge
+}
+
+func newPolicyMetrics(r *metrics.Registry) *policyMetrics {
+	if r == nil {
+		return nil
+	}
+
+	return &policyMetrics{
+		calls:    r.Counter("resilience_calls_total", "Number of attempts per policy by result.", "policy", "result"),
+		retries:  r.Counter("resilience_retries_total", "Number of retried attempts per policy.", "policy"),
+		state:    r.Gauge("resilience_circuit_state", "Circuit breaker state per policy: 0 closed, 1 half-open, 2 open.", "policy"),
+		inflight: r.Gauge("resilience_bulkhead_in_flight", "Number of calls running through the bulkhead per policy.", "policy"),
+	}
+}
+
+func (m *policyMetrics) called(policy, result string) {
+	if m == nil {
+		return
+	}
+	m.calls.Inc(policy, result)
+}
+
+func (m *policyMetrics) retried(policy string) {
+	if m == nil {
+		return
+	}
+	m.retries.Inc(policy)
+}
+
+func (m *policyMetrics) setState(policy string, s State) {
+	if m == nil {
+		return
+	}
+	m.state.Set(float64(s), policy)
+}
+
+func (m *policyMetrics) inFlight(policy string, delta float64) {
+	if m == nil {
+		return
+	}
+	m.inflight.Add(delta, policy)
+}
@@ -0,0 +1,272 @@
+// Package resilience protects calls to flaky dependencies with retries,
+// a circuit breaker, a bulkhead and per-attempt timeouts.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-common-kp/kp/pkg/metrics"
+)
+
+var (
+	// ErrCircuitOpen is returned without calling the dependency while the
+	// circuit breaker is open, or half-open with every probe in flight.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrBulkheadFull is returned without calling the dependency when
+	// MaxConcurrent calls are already running.
+	ErrBulkheadFull = errors.New("bulkhead is full")
+)
+
+// Config configures a Policy. The zero value runs calls once, unprotected.
+type Config struct {
+	Retry   RetryConfig
+	Breaker BreakerConfig
+	// MaxConcurrent limits the calls running at once; calls over the limit
+	// fail with ErrBulkheadFull. 0 means no limit.
+	MaxConcurrent int
+	// Timeout bounds each attempt. 0 means no timeout.
+	Timeout time.Duration
+}
+
+// RetryConfig configures the attempts of a call.
+type RetryConfig struct {
+	// Attempts is the number of attempts of a call, the first one included.
+	// Values below 1 mean 1.
+	Attempts int
+	// Backoff is the wait before the second attempt. It doubles for every
+	// later attempt, up to MaxBackoff when that is set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes every wait by up to this fraction of it, from 0 to 1,
+	// so that clients failing together do not retry together.
+	Jitter float64
+}
+
+// Policy runs calls under a Config. Its circuit breaker and bulkhead are
+// shared by every call, so use one Policy per dependency.
+type Policy struct {
+	name     string
+	conf     Config
+	breaker  *breaker
+	bulkhead chan struct{}
+	log      logger.LoggerService
+	metrics  *policyMetrics
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Policy created by New.
+type Option func(*Policy)
+
+// WithLogger logs the circuit breaker state changes and the retries of the
+// policy to log.
+func WithLogger(log logger.LoggerService) Option {
+	return func(p *Policy) {
+		p.log = log
+	}
+}
+
+// WithMetrics exports the calls, retries, rejections and circuit breaker
+// state of the policy to registry, labelled with the policy name.
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(p *Policy) {
+		p.metrics = newPolicyMetrics(registry)
+	}
+}
+
+// New returns a Policy named name, the name used in logs and metrics.
+func New(name string, conf Config, opts ...Option) *Policy {
+	p := &Policy{name: name, conf: conf, sleep: sleep}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if conf.Breaker.FailureThreshold > 0 {
+		p.breaker = newBreaker(conf.Breaker, p.onStateChange)
+		p.metrics.setState(name, StateClosed)
+	}
+	if conf.MaxConcurrent > 0 {
+		p.bulkhead = make(chan struct{}, conf.MaxConcurrent)
+	}
+	return p
+}
+
+// Name returns the name of the policy.
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// State returns the state of the circuit breaker, StateClosed without one.
+func (p *Policy) State() State {
+	if p == nil || p.breaker == nil {
+		return StateClosed
+	}
+	return p.breaker.currentState()
+}
+
+// Execute calls fn until it succeeds or the attempts of the policy are
+// exhausted, and returns the error of the last attempt. Each attempt gets a
+// context bounded by the Timeout of the policy.
+//
+// Rejected attempts, errors marked with Permanent and the cancellation of ctx
+// end the retries. A nil Policy calls fn once.
+func (p *Policy) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p == nil {
+		return fn(ctx)
+	}
+
+	attempts := max(p.conf.Retry.Attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = p.attempt(ctx, fn)
+		if err == nil || attempt == attempts || !retryable(ctx, err) {
+			break
+		}
+
+		wait := p.backoff(attempt)
+		if p.log != nil {
+			p.log.Debugf("Retrying %s in %s after attempt %d failed: %v", p.name, wait, attempt, err)
+		}
+		p.metrics.retried(p.name)
+		if serr := p.sleep(ctx, wait); serr != nil {
+			break
+		}
+	}
+
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return perm.err
+	}
+	return err
+}
+
+// attempt calls fn once through the bulkhead and the circuit breaker.
+func (p *Policy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.bulkhead != nil {
+		select {
+		case p.bulkhead <- struct{}{}:
+			p.metrics.inFlight(p.name, 1)
+			defer func() {
+				<-p.bulkhead
+				p.metrics.inFlight(p.name, -1)
+			}()
+		default:
+			p.metrics.called(p.name, resultBulkheadFull)
+			return ErrBulkheadFull
+		}
+	}
+
+	var done func(success bool)
+	if p.breaker != nil {
+		var ok bool
+		if done, ok = p.breaker.allow(); !ok {
+			p.metrics.called(p.name, resultCircuitOpen)
+			return ErrCircuitOpen
+		}
+	}
+
+	attemptCtx := ctx
+	if p.conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, p.conf.Timeout)
+		defer cancel()
+	}
+
+	err := fn(attemptCtx)
+	if done != nil {
+		done(err == nil)
+	}
+
+	switch {
+	case err == nil:
+		p.metrics.called(p.name, resultSuccess)
+	case ctx.Err() == nil && attemptCtx.Err() == context.DeadlineExceeded:
+		p.metrics.called(p.name, resultTimeout)
+	default:
+		p.metrics.called(p.name, resultFailure)
+	}
+	return err
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (p *Policy) backoff(failed int) time.Duration {
+	conf := p.conf.Retry
+	if conf.Backoff <= 0 {
+		return 0
+	}
+
+	d := conf.Backoff
+	for i := 1; i < failed; i++ {
+		d *= 2
+		if conf.MaxBackoff > 0 && d >= conf.MaxBackoff {
+			d = conf.MaxBackoff
+			break
+		}
+	}
+
+	if jitter := min(max(conf.Jitter, 0), 1); jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * jitter * float64(d))
+	}
+	return d
+}
+
+func (p *Policy) onStateChange(from, to State) {
+	p.metrics.setState(p.name, to)
+	if p.log == nil {
+		return
+	}
+	if to == StateOpen {
+		p.log.Errorf("Circuit breaker %s changed from %s to %s", p.name, from, to)
+		return
+	}
+	p.log.Logf("Circuit breaker %s changed from %s to %s", p.name, from, to)
+}
+
+// retryable reports whether a call that failed with err is attempted again.
+func retryable(ctx context.Context, err error) bool {
+	var perm *permanentError
+	switch {
+	case ctx.Err() != nil:
+		return false
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrBulkheadFull):
+		return false
+	case errors.As(err, &perm):
+		return false
+	}
+	return true
+}
+
+// Permanent marks err as not worth retrying: Execute returns it, unwrapped,
+// without further attempts. It still counts as a failure of the dependency.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/metrics"
+)
+
+var errDown = errors.New("dependency down")
+
+// recordSleeps replaces the waits of p and returns the recorded durations.
+func recordSleeps(p *Policy) *[]time.Duration {
+	var waits []time.Duration
+	p.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return &waits
+}
+
+func TestExecuteRetriesWithExponentialBackoff(t *testing.T) {
+	p := New("orders", Config{Retry: RetryConfig{Attempts: 4, Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}})
+	waits := recordSleeps(p)
+
+	calls := 0
+	err := p.Execute(context.Background(), func(context.Context) error {
+		calls++
+		return errDown
+	})
+	if !errors.Is(err, errDown) || calls != 4 {
+		t.Fatalf("expected 4 failed attempts, got %d calls and %v", calls, err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	if len(*waits) != len(want) {
+		t.Fatalf("expected waits %v, got %v", want, *waits)
+	}
+	for i, d := range want {
+		if (*waits)[i] != d {
+			t.Errorf("expected waits %v, got %v", want, *waits)
+		}
+	}
+}
+
+func TestExecuteStopsOnSuccessAndPermanentErrors(t *testing.T) {
+	p := New("orders", Config{Retry: RetryConfig{Attempts: 5}})
+	recordSleeps(p)
+
+	calls := 0
+	err := p.Execute(context.Background(), func(context.Context) error {
+		if calls++; calls < 2 {
+			return errDown
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("expected success on the second attempt, got %d calls and %v", calls, err)
+	}
+
+	calls = 0
+	err = p.Execute(context.Background(), func(context.Context) error {
+		calls++
+		return Permanent(errDown)
+	})
+	if err != errDown || calls != 1 {
+		t.Errorf("expected the unwrapped permanent error after one attempt, got %d calls and %v", calls, err)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := New("orders", Config{Retry: RetryConfig{Backoff: time.Second, Jitter: 0.5}})
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("expected a wait within 50%% of 1s, got %s", d)
+		}
+	}
+}
+
+func TestExecuteTimeoutPerAttempt(t *testing.T) {
+	p := New("orders", Config{Timeout: 10 * time.Millisecond, Retry: RetryConfig{Attempts: 2}})
+	recordSleeps(p)
+
+	calls := 0
+	err := p.Execute(context.Background(), func(ctx context.Context) error {
+		calls++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 2 {
+		t.Errorf("expected each attempt to time out, got %d calls and %v", calls, err)
+	}
+}
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	registry := metrics.NewRegistry()
+	p := New("orders", Config{Breaker: BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute}}, WithMetrics(registry))
+	now := time.Now()
+	p.breaker.now = func() time.Time { return now }
+
+	fail := func(context.Context) error { return errDown }
+	succeed := func(context.Context) error { return nil }
+
+	_ = p.Execute(context.Background(), fail)
+	_ = p.Execute(context.Background(), fail)
+	if p.State() != StateOpen {
+		t.Fatalf("expected the circuit open after 2 failures, got %s", p.State())
+	}
+
+	called := false
+	err := p.Execute(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || called {
+		t.Fatalf("expected calls rejected while open, got %v", err)
+	}
+
+	// a failed probe opens the circuit again
+	now = now.Add(time.Minute)
+	if err := p.Execute(context.Background(), fail); !errors.Is(err, errDown) {
+		t.Fatalf("expected the probe to run, got %v", err)
+	}
+	if p.State() != StateOpen {
+		t.Fatalf("expected a failed probe to reopen the circuit, got %s", p.State())
+	}
+
+	now = now.Add(time.Minute)
+	if err := p.Execute(context.Background(), succeed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.State() != StateClosed {
+		t.Errorf("expected a successful probe to close the circuit, got %s", p.State())
+	}
+
+	if got := registry.Counter("resilience_calls_total", "", "policy", "result").Value("orders", resultCircuitOpen); got != 1 {
+		t.Errorf("expected 1 rejected call, got %v", got)
+	}
+	if got := registry.Gauge("resilience_circuit_state", "", "policy").Value("orders"); got != float64(StateClosed) {
+		t.Errorf("expected the closed state exported, got %v", got)
+	}
+}
+
+func TestCircuitBreakerLimitsHalfOpenProbes(t *testing.T) {
+	p := New("orders", Config{Breaker: BreakerConfig{FailureThreshold: 1, HalfOpenProbes: 1}})
+	now := time.Now()
+	p.breaker.now = func() time.Time { return now }
+
+	_ = p.Execute(context.Background(), func(context.Context) error { return errDown })
+	now = now.Add(defaultOpenTimeout)
+
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = p.Execute(context.Background(), func(context.Context) error {
+			close(probing)
+			<-release
+			return nil
+		})
+	}()
+
+	<-probing
+	if err := p.Execute(context.Background(), func(context.Context) error { return nil }); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected calls beyond the probes rejected, got %v", err)
+	}
+	close(release)
+	wg.Wait()
+
+	if p.State() != StateClosed {
+		t.Errorf("expected the circuit closed, got %s", p.State())
+	}
+}
+
+func TestBulkheadRejectsOverLimit(t *testing.T) {
+	p := New("orders", Config{MaxConcurrent: 1, Retry: RetryConfig{Attempts: 3}})
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Execute(context.Background(), func(context.Context) error {
+			close(running)
+			<-release
+			return nil
+		})
+	}()
+
+	<-running
+	calls := 0
+	err := p.Execute(context.Background(), func(context.Context) error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, ErrBulkheadFull) || calls != 0 {
+		t.Errorf("expected the call rejected without retries, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Execute(context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Errorf("expected a free slot after the call returned, got %v", err)
+	}
+}
+
+func TestNilPolicyCallsOnce(t *testing.T) {
+	var p *Policy
+	calls := 0
+	if err := p.Execute(context.Background(), func(context.Context) error {
+		calls++
+		return errDown
+	}); !errors.Is(err, errDown) || calls != 1 {
+		t.Errorf("expected one call, got %d and %v", calls, err)
+	}
+}
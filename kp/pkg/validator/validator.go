@@ -0,0 +1,295 @@
+// Package validator checks structs against the rules of their validate tags:
+//
+//	type Order struct {
+//		Email string `json:"email" validate:"required,email"`
+//		Size  string `json:"size" validate:"oneof=S M L"`
+//		Items []Item `json:"items" validate:"required,min=1,max=50"`
+//	}
+//
+// Nested structs, pointers to structs and the elements of slices, arrays and
+// maps are validated too.
+package validator
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const tagName = "validate"
+
+// FieldError reports a field that breaks a rule. Field is the path of the
+// field in the request, using the json or form names: "items[0].name".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors lists every field error of a validated value.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Struct validates v, a struct or a pointer to one. It returns
+// ValidationErrors when fields break their rules, and another error when a
+// validate tag is malformed.
+func Struct(v any) error {
+	var errs ValidationErrors
+	if err := validate(reflect.ValueOf(v), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate checks the fields of v and walks into its elements.
+func validate(v reflect.Value, path string, errs *ValidationErrors) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() && !(field.Anonymous && indirectType(field.Type).Kind() == reflect.Struct) {
+				continue
+			}
+
+			// embedded structs are flattened, as by encoding/json
+			name := path
+			if !field.Anonymous || field.Tag.Get("json") != "" {
+				name = joinPath(path, fieldName(field))
+			}
+			fv := v.Field(i)
+			tag := field.Tag.Get(tagName)
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				skip, err := checkRules(fv, name, tag, errs)
+				if err != nil {
+					return err
+				}
+				if skip {
+					continue
+				}
+			}
+			if err := validate(fv, name, errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validate(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validate(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules checks the field v against the rules of tag. skip reports that
+// the field is empty and optional, or nil, so its elements are not walked.
+func checkRules(v reflect.Value, name, tag string, errs *ValidationErrors) (skip bool, err error) {
+	rules := strings.Split(tag, ",")
+	empty := isEmpty(v)
+
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "omitempty" && empty {
+			return true, nil
+		}
+	}
+
+	v = indirect(v)
+	for _, rule := range rules {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		var msg string
+		switch rule {
+		case "", "omitempty":
+			continue
+		case "required":
+			if empty {
+				msg = name + " is required"
+			}
+		case "min", "max", "len":
+			if !v.IsValid() {
+				continue
+			}
+			if msg, err = checkSize(v, name, rule, param); err != nil {
+				return false, err
+			}
+		case "email":
+			if !v.IsValid() {
+				continue
+			}
+			if v.Kind() != reflect.String {
+				return false, fmt.Errorf("validator: email rule on non-string field %s", name)
+			}
+			if addr, err := mail.ParseAddress(v.String()); err != nil || addr.Address != v.String() {
+				msg = name + " must be a valid email address"
+			}
+		case "oneof":
+			if !v.IsValid() {
+				continue
+			}
+			if msg, err = checkOneOf(v, name, param); err != nil {
+				return false, err
+			}
+		default:
+			return false, fmt.Errorf("validator: unknown rule %q on field %s", rule, name)
+		}
+
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Rule: rule, Param: param, Message: msg})
+		}
+	}
+	return !v.IsValid(), nil
+}
+
+// checkSize checks the length of strings and collections, or the value of
+// numbers, against the min, max or len rule.
+func checkSize(v reflect.Value, name, rule, param string) (string, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("validator: invalid %s parameter %q on field %s", rule, param, name)
+	}
+
+	var size float64
+	var unit string
+	switch v.Kind() {
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(v.String())), "characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size, unit = float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	default:
+		return "", fmt.Errorf("validator: %s rule on unsupported field %s of kind %s", rule, name, v.Kind())
+	}
+
+	switch {
+	case rule == "min" && size < limit && unit != "":
+		return fmt.Sprintf("%s must have at least %s %s", name, param, unit), nil
+	case rule == "min" && size < limit:
+		return fmt.Sprintf("%s must be %s or greater", name, param), nil
+	case rule == "max" && size > limit && unit != "":
+		return fmt.Sprintf("%s must have at most %s %s", name, param, unit), nil
+	case rule == "max" && size > limit:
+		return fmt.Sprintf("%s must be %s or less", name, param), nil
+	case rule == "len" && size != limit && unit != "":
+		return fmt.Sprintf("%s must have exactly %s %s", name, param, unit), nil
+	case rule == "len" && size != limit:
+		return fmt.Sprintf("%s must be %s", name, param), nil
+	}
+	return "", nil
+}
+
+// checkOneOf checks that a string or a number is one of the space separated
+// values of param.
+func checkOneOf(v reflect.Value, name, param string) (string, error) {
+	switch v.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return "", fmt.Errorf("validator: oneof rule on unsupported field %s of kind %s", name, v.Kind())
+	}
+
+	var value string
+	switch v.Kind() {
+	case reflect.String:
+		value = v.String()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = strconv.FormatUint(v.Uint(), 10)
+	default:
+		value = strconv.FormatInt(v.Int(), 10)
+	}
+	options := strings.Fields(param)
+	for _, option := range options {
+		if value == option {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("%s must be one of [%s]", name, strings.Join(options, " ")), nil
+}
+
+// isEmpty reports whether v is nil, its zero value or an empty collection.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return !v.IsValid() || v.IsZero()
+}
+
+// indirect follows pointers and interfaces, returning the zero Value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// fieldName returns the name of field in requests: its json name, else its
+// form name, else its Go name.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
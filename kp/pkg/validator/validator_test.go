@@ -0,0 +1,128 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type item struct {
+	SKU      string `json:"sku" validate:"required,len=4"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+type order struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Name     string   `json:"name" validate:"required,min=1,max=5"`
+	Size     string   `json:"size" validate:"omitempty,oneof=S M L"`
+	Priority int      `form:"priority" validate:"oneof=1 2 3"`
+	Address  *address `json:"address" validate:"required"`
+	Billing  *address `json:"billing"`
+	Items    []item   `json:"items" validate:"required,max=2"`
+	Note     string   `json:"-" validate:"max=3"`
+}
+
+func fields(err error) []string {
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	names := make([]string, len(errs))
+	for i, fe := range errs {
+		names[i] = fe.Field + ":" + fe.Rule
+	}
+	return names
+}
+
+func TestStructValid(t *testing.T) {
+	o := order{
+		Email:    "jane@example.com",
+		Name:     "Jane",
+		Priority: 2,
+		Address:  &address{City: "Bangkok"},
+		Items:    []item{{SKU: "A001", Quantity: 1}},
+	}
+	if err := Struct(&o); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStructReportsEveryField(t *testing.T) {
+	o := order{
+		Email:    "not-an-email",
+		Name:     "Jonathan",
+		Size:     "XL",
+		Priority: 7,
+		Billing:  &address{},
+		Items:    []item{{SKU: "A001", Quantity: 1}, {SKU: "B", Quantity: 11}, {SKU: "C003"}},
+		Note:     "long",
+	}
+
+	want := []string{
+		"email:email",
+		"name:max",
+		"size:oneof",
+		"priority:oneof",
+		"address:required",
+		"billing.city:required",
+		"items:max",
+		"items[1].sku:len",
+		"items[1].quantity:max",
+		"items[2].quantity:min",
+		"Note:max",
+	}
+	if got := fields(Struct(o)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected field errors\n%v\ngot\n%v", want, got)
+	}
+}
+
+func TestStructMessages(t *testing.T) {
+	err := Struct(&item{SKU: "A", Quantity: 0})
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected 2 field errors, got %v", err)
+	}
+	if errs[0].Message != "sku must have exactly 4 characters" || errs[0].Param != "4" {
+		t.Errorf("unexpected error %+v", errs[0])
+	}
+	if errs[1].Message != "quantity must be 1 or greater" {
+		t.Errorf("unexpected error %+v", errs[1])
+	}
+}
+
+func TestStructEmbeddedFieldsAreFlattened(t *testing.T) {
+	type base struct {
+		ID string `json:"id" validate:"required"`
+	}
+	type customer struct {
+		base
+		Home address `json:"home"`
+	}
+	type withBase struct {
+		address
+	}
+
+	if got := fields(Struct(withBase{})); !reflect.DeepEqual(got, []string{"city:required"}) {
+		t.Errorf("expected the embedded field at the top level, got %v", got)
+	}
+	if got := fields(Struct(customer{})); !reflect.DeepEqual(got, []string{"id:required", "home.city:required"}) {
+		t.Errorf("expected the embedded and nested fields, got %v", got)
+	}
+}
+
+func TestStructInvalidTag(t *testing.T) {
+	type bad struct {
+		Name string `validate:"required,uuid"`
+	}
+
+	err := Struct(bad{Name: "x"})
+	var errs ValidationErrors
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("expected a tag error, got %v", err)
+	}
+}